@@ -4,6 +4,7 @@ import (
 	"bytes"
 	std "encoding/binary"
 	"encoding/gob"
+	"io/ioutil"
 	"reflect"
 	"testing"
 )
@@ -100,6 +101,42 @@ func BenchmarkDecodeRegedStruct(b *testing.B) {
 	testBenchDecode(b, &data, &dataC, "BenchmarkDecodeRegedStruct")
 }
 
+func BenchmarkAcquireEncoderStruct(b *testing.B) {
+	data := _struct
+	b.SetBytes(int64(Sizeof(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encoder := AcquireEncoder(0)
+		encoder.Value(&data)
+		ReleaseEncoder(encoder)
+	}
+	b.StopTimer()
+}
+func BenchmarkAcquireDecoderStruct(b *testing.B) {
+	data := _struct
+	bs, _ := Encode(&data, nil)
+	b.SetBytes(int64(len(bs)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decoder := AcquireDecoder(bs)
+		decoder.Value(&wStruct)
+		ReleaseDecoder(decoder)
+	}
+	b.StopTimer()
+}
+func BenchmarkWriteStructDiscard(b *testing.B) {
+	data := _struct
+	b.SetBytes(int64(Sizeof(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Write(ioutil.Discard, LittleEndian, &data)
+	}
+	b.StopTimer()
+}
+
 //////////////////////////////////////////////////////////////////Int1000
 func BenchmarkGobEncodeInt1000(b *testing.B) {
 	data := u32Array1000
@@ -134,6 +171,34 @@ func BenchmarkUnackInt1000(b *testing.B) {
 	testBenchDecode(b, &data, &u32Array1000W, "BenchmarkUnackInt1000")
 }
 
+//////////////////////////////////////////////////////////////////Numbers
+type samplesStruct struct {
+	Samples numberSamples
+	Levels  [256]uint16
+}
+
+var _samples = samplesStruct{Samples: make(numberSamples, 256)}
+
+func BenchmarkEncodeSamples(b *testing.B) {
+	data := _samples
+	testBenchEncode(b, &data, "BenchmarkEncodeSamples")
+}
+func BenchmarkDecodeSamples(b *testing.B) {
+	data := _samples
+	var w samplesStruct
+	testBenchDecode(b, &data, &w, "BenchmarkDecodeSamples")
+}
+func BenchmarkWriteSamplesBigEndian(b *testing.B) {
+	data := _samples
+	b.SetBytes(int64(Sizeof(&data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buffer.Reset()
+		Write(buffer, BigEndian, &data)
+	}
+	b.StopTimer()
+}
+
 //////////////////////////////////////////////////////////////////String
 func BenchmarkGobEncodeString(b *testing.B) {
 	data := str
@@ -168,6 +233,26 @@ func BenchmarkUnackString(b *testing.B) {
 	testBenchDecode(b, &data, &strW, "BenchmarkUnackString")
 }
 
+//////////////////////////////////////////////////////////////////Bytes
+type bytesStruct struct {
+	Key   string
+	Value []byte
+	Hash  [32]byte
+}
+
+var _bytes = bytesStruct{Key: str[:16], Value: []byte(str), Hash: [32]byte{1, 2, 3, 4}}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	data := _bytes
+	var w bytesStruct
+	testBenchDecode(b, &data, &w, "BenchmarkDecodeBytes")
+}
+func BenchmarkDecodeBytesZeroCopy(b *testing.B) {
+	data := _bytes
+	var w bytesStruct
+	testBenchDecodeZeroCopy(b, &data, &w, "BenchmarkDecodeBytesZeroCopy")
+}
+
 //func newSame(v reflect.Value) (value reflect.Value) {
 //	vv := reflect.Indirect(v)
 //	t := vv.Type()
@@ -325,3 +410,22 @@ func testBenchDecode(b *testing.B, data, w interface{}, caseName string) {
 		b.Fatalf("%s doesn't match:\ngot  %#v;\nwant %#v", caseName, w, data)
 	}
 }
+func testBenchDecodeZeroCopy(b *testing.B, data, w interface{}, caseName string) {
+	buf, err := Encode(data, buff)
+	if err != nil {
+		b.Error(caseName, err)
+	}
+	b.SetBytes(int64(len(buf)))
+
+	var decoder Decoder
+	decoder.SetZeroCopy(true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decoder.Init(buf, DefaultEndian)
+		decoder.Value(w)
+	}
+	b.StopTimer()
+	if b.N > 0 && !reflect.DeepEqual(data, w) {
+		b.Fatalf("%s doesn't match:\ngot  %#v;\nwant %#v", caseName, w, data)
+	}
+}
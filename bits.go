@@ -0,0 +1,166 @@
+// bit fields of structs with tag bits=N.
+
+package binary
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Integer fields with tag `binary:"bits=N"` are encoded in N bits.
+// Consecutive bit fields make a group that is encoded in bytes of it's own,
+// bools and other groups never share these bytes:
+//
+//	bits are filled from the most significant bit of the first byte in order of fields,
+//	each value is written from it's most significant bit, the byte order is not used
+//	unused low bits of the last byte are zero
+//	signed values are in two's complement of N bits
+//	values out of range of N bits fail when encoding
+//
+// So that protocol headers can be described declaratively, eg:
+//
+//	type IPv4Header struct {
+//		Version uint8  `binary:"bits=4"`
+//		IHL     uint8  `binary:"bits=4"`
+//		DSCP    uint8  `binary:"bits=6"`
+//		ECN     uint8  `binary:"bits=2"`
+//		Length  uint16 `binary:"be"`
+//	}
+//
+// where Version=4 and IHL=5 are encoded as byte 0x45.
+// An ignored field ends the group. Bit fields work for both registed and
+// unregisted structs, but not for versioned structs and SchemaOf.
+
+// fieldBits is the bit field encoding of a struct field.
+type fieldBits struct {
+	n    int //N of bits=N
+	off  int //bit offset in it's group
+	size int //number of bytes of it's group
+}
+
+// checkBits checks tag bits=N of a field of type t.
+func checkBits(t reflect.Type, tag fieldTag) error {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return fmt.Errorf("bits=%d is valid for integers only, but got %s", tag.bits, t.String())
+	}
+	if checkCustom(t) != customNone || stdTypeOf(t) != nil { //customOf locks the registry
+		return fmt.Errorf("bits=%d is not valid for type %s that encodes itself", tag.bits, t.String())
+	}
+	if tag.bits > t.Bits() {
+		return fmt.Errorf("bits=%d exceeds size of %s", tag.bits, t.String())
+	}
+	if tag.packed {
+		return fmt.Errorf("bits=%d can not be packed", tag.bits)
+	}
+	return nil
+}
+
+// groupBits set offsets and group sizes of consecutive bit fields.
+func (info *structInfo) groupBits() {
+	for i, n := 0, len(info.fields); i < n; {
+		if info.fields[i].bits.n == 0 {
+			i++
+			continue
+		}
+		j, off := i, 0
+		for ; j < n && info.fields[j].bits.n > 0; j++ {
+			info.fields[j].bits.off = off
+			off += info.fields[j].bits.n
+		}
+		for ; i < j; i++ {
+			info.fields[i].bits.size = (off + 7) / 8
+		}
+	}
+}
+
+// minSize returns number of bytes of the group that start with this field.
+func (b *fieldBits) minSize() int {
+	if b.off == 0 {
+		return b.size
+	}
+	return 0
+}
+
+// bitsOf returns number of bits to encode bit field v, or -1 if it is out of range.
+// The bits of a group are counted by it's first field.
+func (b *fieldBits) bitsOf(v reflect.Value) int {
+	if _, ok := b.value(v); !ok {
+		return -1
+	}
+	return b.minSize() * 8
+}
+
+// mask returns the low N bits.
+func (b *fieldBits) mask() uint64 {
+	return math.MaxUint64 >> uint(64-b.n)
+}
+
+// value returns low N bits of integer v, ok is false if v is out of range.
+func (b *fieldBits) value(v reflect.Value) (x uint64, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if b.n < 64 && (i < -1<<uint(b.n-1) || i >= 1<<uint(b.n-1)) {
+			return 0, false
+		}
+		return uint64(i) & b.mask(), true
+	}
+	x = v.Uint()
+	return x, x&^b.mask() == 0
+}
+
+// set N bits x to integer v.
+func (b *fieldBits) set(v reflect.Value, x uint64) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if x&(1<<uint(b.n-1)) != 0 { //negative
+			x |= ^b.mask()
+		}
+		v.SetInt(int64(x))
+	default:
+		v.SetUint(x)
+	}
+}
+
+// bitField encode bit field v into group and returns the group.
+// The bytes of group are reserved by the first field of it.
+func (encoder *Encoder) bitField(v reflect.Value, f *fieldInfo, group []byte) []byte {
+	b := &f.bits
+	if b.off == 0 {
+		group = encoder.reserve(b.size)
+		for i := range group {
+			group[i] = 0
+		}
+	}
+	x, ok := b.value(v)
+	if !ok {
+		panic(fmt.Errorf("binary.Encoder.Value: %v overflows bits=%d", v, b.n))
+	}
+	for i := 0; i < b.n; i++ {
+		if x&(1<<uint(b.n-1-i)) != 0 {
+			p := b.off + i
+			group[p/8] |= 0x80 >> uint(p%8)
+		}
+	}
+	return group
+}
+
+// bitField decode bit field v from group and returns the group.
+// The bytes of group are reserved by the first field of it.
+func (decoder *Decoder) bitField(v reflect.Value, f *fieldInfo, group []byte) []byte {
+	b := &f.bits
+	if b.off == 0 {
+		group = decoder.reserve(b.size)
+	}
+	var x uint64
+	for i := 0; i < b.n; i++ {
+		p := b.off + i
+		x = x<<1 | uint64(group[p/8]>>uint(7-p%8)&1)
+	}
+	b.set(v, x)
+	return group
+}
@@ -0,0 +1,129 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bitsHeader struct {
+	Version uint8 `binary:"bits=4"`
+	IHL     uint8 `binary:"bits=4"`
+	Flag    bool
+	Small   int8   `binary:"bits=3"`
+	Big     uint16 `binary:"bits=10"`
+	Neg     int    `binary:"bits=5"`
+	Skip    uint8  `binary:"ignore"`
+	Last    uint64 `binary:"bits=64"`
+	Ok      bool
+	Length  uint16 `binary:"be"`
+}
+
+func TestBits(t *testing.T) {
+	x := bitsHeader{
+		Version: 4,
+		IHL:     5,
+		Flag:    true,
+		Small:   -3,
+		Big:     0x2ab,
+		Neg:     -1,
+		Last:    0x0102030405060708,
+		Ok:      true,
+		Length:  16,
+	}
+	want := []byte{
+		0x45,             //Version, IHL
+		0x03,             //Flag, Ok
+		0xb5, 0x5f, 0xc0, //Small, Big, Neg
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, //Last
+		0x00, 0x10, //Length
+	}
+	b, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Encode:\nhave %x\nwant %x", b, want)
+	}
+	if n := Sizeof(&x); n != len(want) {
+		t.Errorf("Sizeof: have %d, want %d", n, len(want))
+	}
+	var y bitsHeader
+	if err := Decode(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(y, x) {
+		t.Errorf("Decode:\nhave %+v\nwant %+v", y, x)
+	}
+
+	//skip elements out of array
+	b, err = Encode([]bitsHeader{x, x}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, 0x7f)
+	var a [1]bitsHeader
+	var end int8
+	decoder := NewDecoder(b)
+	if err := decoder.Value(&a); err != nil || !reflect.DeepEqual(a[0], x) {
+		t.Errorf("Decode array: have %+v, %v", a[0], err)
+	}
+	if err := decoder.Value(&end); err != nil || end != 0x7f {
+		t.Errorf("Decode after skipped elements: have %d, %v", end, err)
+	}
+}
+
+func TestBitsError(t *testing.T) {
+	for _, x := range []bitsHeader{{Version: 16}, {Small: 4}, {Small: -5}, {Big: 1 << 10}, {Neg: -17}} {
+		if _, err := Encode(&x, nil); err == nil || !strings.Contains(err.Error(), "overflows bits=") {
+			t.Errorf("Encode %+v: have %v, want overflow error", x, err)
+		}
+		if n := Sizeof(&x); n != -1 {
+			t.Errorf("Sizeof %+v: have %d, want -1", x, n)
+		}
+	}
+	var truncated *TruncatedError
+	var x bitsHeader
+	if err := Decode([]byte{0x45, 0x03, 0xb5}, &x); !errors.As(err, &truncated) {
+		t.Errorf("Decode truncated: have %v, want *TruncatedError", err)
+	}
+
+	var tagErr *TagError
+	for _, data := range []interface{}{
+		&struct {
+			A string `binary:"bits=4"`
+		}{},
+		&struct {
+			A uint8 `binary:"bits=9"`
+		}{},
+		&struct {
+			A uint64 `binary:"bits=0"`
+		}{},
+		&struct {
+			A uint64 `binary:"bits=65"`
+		}{},
+		&struct {
+			A time.Duration `binary:"bits=8"`
+		}{},
+		&struct {
+			A uint32 `binary:"bits=8,packed"`
+		}{},
+		&struct {
+			N uint8  `binary:"bits=8"`
+			A string `binary:"lenfield=N"`
+		}{},
+		&struct {
+			A uint8 `binary:"id=1,bits=8"`
+		}{},
+	} {
+		if _, err := Encode(data, nil); !errors.As(err, &tagErr) {
+			t.Errorf("Encode %T: have %v, want *TagError", data, err)
+		}
+	}
+	if _, err := SchemaOf(reflect.TypeOf(x)); !errors.As(err, &tagErr) {
+		t.Errorf("SchemaOf: have %v, want *TagError", err)
+	}
+}
@@ -0,0 +1,304 @@
+// general-purpose bit stream writer and reader.
+
+package binary
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// BitOrder is the order of bits in each byte of a bit stream.
+type BitOrder uint8
+
+const (
+	// MSBFirst fills bytes from the most significant bit, and values are written
+	// from their most significant bit, as bit fields of tag bits=N.
+	MSBFirst BitOrder = iota
+	// LSBFirst fills bytes from the least significant bit, and values are written
+	// from their least significant bit, as bools of Encoder.
+	LSBFirst
+)
+
+// BitWriter writes a bit stream to buffer.
+// The byte that is being filled is zero padded in Buffer.
+// Exp-Golomb and Elias-gamma codes are written bit by bit in stream order,
+// so they are the same sequence of bits in both bit orders.
+type BitWriter struct {
+	coder //boolPos and boolBit are the byte that is being filled
+	order BitOrder
+}
+
+// NewBitWriter make a new BitWriter object with buffer size and bit order.
+func NewBitWriter(size int, order BitOrder) *BitWriter {
+	return NewBitWriterBuffer(make([]byte, size), order)
+}
+
+// NewBitWriterBuffer make a new BitWriter object with buffer and bit order.
+func NewBitWriterBuffer(buffer []byte, order BitOrder) *BitWriter {
+	p := &BitWriter{order: order}
+	p.buff = buffer
+	p.resetBoolCoder()
+	return p
+}
+
+// BitLen returns number of bits that has been written.
+func (w *BitWriter) BitLen() int {
+	return bitLen(&w.coder)
+}
+
+// Aligned returns if the next bit will be written to a new byte.
+func (w *BitWriter) Aligned() bool {
+	return w.boolBit == 0
+}
+
+// Align pads the byte that is being filled with zero bits,
+// so that the next bit will be written to a new byte.
+// It returns number of padded bits.
+func (w *BitWriter) Align() int {
+	n := 0
+	if w.boolBit != 0 {
+		n = 8 - int(w.boolBit)
+	}
+	w.resetBoolCoder()
+	return n
+}
+
+// WriteBit write a bit, 1 for true and 0 for false.
+// It will panic if buffer is not enough.
+func (w *BitWriter) WriteBit(x bool) {
+	w.WriteBits(uint64(boolByte(x)), 1)
+}
+
+// WriteBits write the low n bits of v, 0 <= n <= 64.
+// It will panic if buffer is not enough.
+func (w *BitWriter) WriteBits(v uint64, n int) {
+	if n < 0 || n > 64 {
+		panic(fmt.Errorf("binary.BitWriter.WriteBits: invalid number of bits %d", n))
+	}
+	for n > 0 {
+		if w.boolBit == 0 {
+			b := w.reserve(1)
+			b[0] = 0
+			w.boolPos = w.pos - 1
+		}
+		free := 8 - int(w.boolBit)
+		k := n
+		if k > free {
+			k = free
+		}
+		mask := uint64(1)<<uint(k) - 1
+		if w.order == MSBFirst {
+			w.buff[w.boolPos] |= byte((v>>uint(n-k))&mask) << uint(free-k)
+		} else {
+			w.buff[w.boolPos] |= byte(v&mask) << w.boolBit
+			v >>= uint(k)
+		}
+		n -= k
+		w.boolBit = byte((int(w.boolBit) + k) % 8)
+	}
+}
+
+// writeCode write the low n bits of v from it's most significant bit in both bit orders.
+func (w *BitWriter) writeCode(v uint64, n int) {
+	if w.order == LSBFirst && n > 0 {
+		v = bits.Reverse64(v) >> uint(64-n)
+	}
+	w.WriteBits(v, n)
+}
+
+// writeGamma write Elias-gamma code of a value of n bits, 1 <= n <= 65,
+// and low n-1 bits of the value are v.
+func (w *BitWriter) writeGamma(n int, v uint64) {
+	for z := n - 1; z > 0; z -= 64 { //zero prefix
+		if z > 64 {
+			w.WriteBits(0, 64)
+		} else {
+			w.WriteBits(0, z)
+		}
+	}
+	w.WriteBits(1, 1)
+	w.writeCode(v, n-1)
+}
+
+// WriteEliasGamma write v > 0 as Elias-gamma code.
+// It will panic if v is 0 or buffer is not enough.
+func (w *BitWriter) WriteEliasGamma(v uint64) {
+	if v == 0 {
+		panic(fmt.Errorf("binary.BitWriter.WriteEliasGamma: 0 can not be encoded"))
+	}
+	w.writeGamma(bits.Len64(v), v)
+}
+
+// WriteExpGolomb write v as unsigned Exp-Golomb code, ue(v) of H.264.
+// It will panic if buffer is not enough.
+func (w *BitWriter) WriteExpGolomb(v uint64) {
+	if v == math.MaxUint64 { //v+1 is 2^64
+		w.writeGamma(65, 0)
+		return
+	}
+	w.writeGamma(bits.Len64(v+1), v+1)
+}
+
+// WriteSignedExpGolomb write v as signed Exp-Golomb code, se(v) of H.264,
+// which is ue of 2v-1 for v > 0 and -2v for v <= 0.
+// It will panic if buffer is not enough.
+func (w *BitWriter) WriteSignedExpGolomb(v int64) {
+	switch {
+	case v > 0:
+		w.WriteExpGolomb(uint64(v)*2 - 1)
+	case v == math.MinInt64: //ue of 2^64, the value is 2^64+1
+		w.writeGamma(65, 1)
+	default:
+		w.WriteExpGolomb(uint64(-v) * 2)
+	}
+}
+
+// BitReader reads a bit stream from buffer.
+// See BitWriter.
+type BitReader struct {
+	coder //boolPos and boolBit are the byte that is being read
+	order BitOrder
+}
+
+// NewBitReader make a new BitReader object with buffer and bit order.
+func NewBitReader(buffer []byte, order BitOrder) *BitReader {
+	p := &BitReader{order: order}
+	p.buff = buffer
+	p.resetBoolCoder()
+	return p
+}
+
+// BitLen returns number of bits that has been read.
+func (r *BitReader) BitLen() int {
+	return bitLen(&r.coder)
+}
+
+// Aligned returns if the next bit will be read from a new byte.
+func (r *BitReader) Aligned() bool {
+	return r.boolBit == 0
+}
+
+// Align skips the rest bits of the byte that is being read,
+// so that the next bit will be read from a new byte.
+// It returns number of skipped bits.
+func (r *BitReader) Align() int {
+	n := 0
+	if r.boolBit != 0 {
+		n = 8 - int(r.boolBit)
+	}
+	r.resetBoolCoder()
+	return n
+}
+
+// ReadBit read a bit, true for 1 and false for 0.
+// It will panic if buffer is not enough.
+func (r *BitReader) ReadBit() bool {
+	return r.ReadBits(1) != 0
+}
+
+// ReadBits read n bits, 0 <= n <= 64.
+// It will panic with *TruncatedError if buffer is not enough.
+func (r *BitReader) ReadBits(n int) uint64 {
+	if n < 0 || n > 64 {
+		panic(fmt.Errorf("binary.BitReader.ReadBits: invalid number of bits %d", n))
+	}
+	pending := 0 //bits left in the byte that is being read
+	if r.boolBit != 0 {
+		pending = 8 - int(r.boolBit)
+	}
+	if need := (n - pending + 7) / 8; need > len(r.buff)-r.pos {
+		panic(&TruncatedError{Offset: int64(r.pos), Need: need, Have: len(r.buff) - r.pos})
+	}
+	var v uint64
+	for shift := 0; n > 0; {
+		if r.boolBit == 0 {
+			r.reserve(1)
+			r.boolPos = r.pos - 1
+		}
+		free := 8 - int(r.boolBit)
+		k := n
+		if k > free {
+			k = free
+		}
+		mask := byte(1)<<uint(k) - 1
+		if r.order == MSBFirst {
+			v = v<<uint(k) | uint64(r.buff[r.boolPos]>>uint(free-k)&mask)
+		} else {
+			v |= uint64(r.buff[r.boolPos]>>r.boolBit&mask) << uint(shift)
+			shift += k
+		}
+		n -= k
+		r.boolBit = byte((int(r.boolBit) + k) % 8)
+	}
+	return v
+}
+
+// readCode read n bits that are written by writeCode.
+func (r *BitReader) readCode(n int) uint64 {
+	v := r.ReadBits(n)
+	if r.order == LSBFirst && n > 0 {
+		v = bits.Reverse64(v) >> uint(64-n)
+	}
+	return v
+}
+
+// readGamma read Elias-gamma code of a value of n bits, 1 <= n <= 65,
+// and low n-1 bits of the value are v.
+func (r *BitReader) readGamma(op string) (n int, v uint64) {
+	for n = 1; !r.ReadBit(); n++ {
+		if n == 65 {
+			panic(fmt.Errorf("binary.BitReader.%s: overflow 64-bits value(pos:%d/%d)", op, r.pos, len(r.buff)))
+		}
+	}
+	return n, r.readCode(n - 1)
+}
+
+// ReadEliasGamma read an Elias-gamma code.
+// It will panic if buffer is not enough or the value overflows uint64.
+func (r *BitReader) ReadEliasGamma() uint64 {
+	n, v := r.readGamma("ReadEliasGamma")
+	if n == 65 {
+		panic(fmt.Errorf("binary.BitReader.ReadEliasGamma: overflow 64-bits value(pos:%d/%d)", r.pos, len(r.buff)))
+	}
+	return 1<<uint(n-1) | v
+}
+
+// ReadExpGolomb read an unsigned Exp-Golomb code.
+// It will panic if buffer is not enough or the value overflows uint64.
+func (r *BitReader) ReadExpGolomb() uint64 {
+	n, v := r.readGamma("ReadExpGolomb")
+	if n == 65 {
+		if v != 0 {
+			panic(fmt.Errorf("binary.BitReader.ReadExpGolomb: overflow 64-bits value(pos:%d/%d)", r.pos, len(r.buff)))
+		}
+		return math.MaxUint64
+	}
+	return (1<<uint(n-1) | v) - 1
+}
+
+// ReadSignedExpGolomb read a signed Exp-Golomb code.
+// It will panic if buffer is not enough or the value overflows int64.
+func (r *BitReader) ReadSignedExpGolomb() int64 {
+	n, v := r.readGamma("ReadSignedExpGolomb")
+	if n == 65 {
+		if v != 1 {
+			panic(fmt.Errorf("binary.BitReader.ReadSignedExpGolomb: overflow 64-bits value(pos:%d/%d)", r.pos, len(r.buff)))
+		}
+		return math.MinInt64
+	}
+	u := (1<<uint(n-1) | v) - 1
+	if u&1 != 0 {
+		return int64(u>>1) + 1
+	}
+	return -int64(u >> 1)
+}
+
+// bitLen returns number of bits before the next bit of bit stream.
+func bitLen(cder *coder) int {
+	n := cder.pos * 8
+	if cder.boolBit != 0 {
+		n -= 8 - int(cder.boolBit)
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestBitStream(t *testing.T) {
+	for _, c := range []struct {
+		order BitOrder
+		want  []byte
+	}{
+		{MSBFirst, []byte{0x45, 0xcd, 0x80}},
+		{LSBFirst, []byte{0x54, 0x9b, 0x02}},
+	} {
+		w := NewBitWriter(3, c.order)
+		w.WriteBits(4, 4)
+		w.WriteBits(0x1005, 4) //high bits are ignored
+		w.WriteBit(true)
+		w.WriteBits(0x4d, 7)
+		if !w.Aligned() || w.BitLen() != 16 {
+			t.Errorf("%d: have aligned %v BitLen %d, want true 16", c.order, w.Aligned(), w.BitLen())
+		}
+		w.WriteBits(2, 2)
+		if n := w.Align(); n != 6 || w.BitLen() != 24 {
+			t.Errorf("%d: Align have %d BitLen %d, want 6 24", c.order, n, w.BitLen())
+		}
+		if b := w.Buffer(); !bytes.Equal(b, c.want) {
+			t.Errorf("%d: have %x, want %x", c.order, b, c.want)
+		}
+
+		r := NewBitReader(c.want, c.order)
+		if x := r.ReadBits(4); x != 4 {
+			t.Errorf("%d: ReadBits have %d, want 4", c.order, x)
+		}
+		if x := r.ReadBits(4); x != 5 {
+			t.Errorf("%d: ReadBits have %d, want 5", c.order, x)
+		}
+		if !r.ReadBit() {
+			t.Errorf("%d: ReadBit have false, want true", c.order)
+		}
+		if x := r.ReadBits(7); x != 0x4d {
+			t.Errorf("%d: ReadBits have %x, want 4d", c.order, x)
+		}
+		if x := r.ReadBits(2); x != 2 {
+			t.Errorf("%d: ReadBits have %d, want 2", c.order, x)
+		}
+		if n := r.Align(); n != 6 || r.BitLen() != 24 || r.ReadBits(0) != 0 {
+			t.Errorf("%d: Align have %d BitLen %d, want 6 24", c.order, n, r.BitLen())
+		}
+	}
+}
+
+func TestBitStreamCodes(t *testing.T) {
+	//bits of codes in stream order
+	for _, c := range []struct {
+		write func(w *BitWriter)
+		read  func(r *BitReader) interface{}
+		value interface{}
+		code  string
+	}{
+		{func(w *BitWriter) { w.WriteExpGolomb(0) }, func(r *BitReader) interface{} { return r.ReadExpGolomb() }, uint64(0), "1"},
+		{func(w *BitWriter) { w.WriteExpGolomb(1) }, func(r *BitReader) interface{} { return r.ReadExpGolomb() }, uint64(1), "010"},
+		{func(w *BitWriter) { w.WriteExpGolomb(6) }, func(r *BitReader) interface{} { return r.ReadExpGolomb() }, uint64(6), "00111"},
+		{func(w *BitWriter) { w.WriteSignedExpGolomb(1) }, func(r *BitReader) interface{} { return r.ReadSignedExpGolomb() }, int64(1), "010"},
+		{func(w *BitWriter) { w.WriteSignedExpGolomb(-1) }, func(r *BitReader) interface{} { return r.ReadSignedExpGolomb() }, int64(-1), "011"},
+		{func(w *BitWriter) { w.WriteSignedExpGolomb(-3) }, func(r *BitReader) interface{} { return r.ReadSignedExpGolomb() }, int64(-3), "00111"},
+		{func(w *BitWriter) { w.WriteEliasGamma(1) }, func(r *BitReader) interface{} { return r.ReadEliasGamma() }, uint64(1), "1"},
+		{func(w *BitWriter) { w.WriteEliasGamma(9) }, func(r *BitReader) interface{} { return r.ReadEliasGamma() }, uint64(9), "0001001"},
+	} {
+		for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+			w := NewBitWriter(8, order)
+			c.write(w)
+			code := ""
+			r := NewBitReader(w.Buffer(), order)
+			for i := 0; i < w.BitLen(); i++ {
+				code += string('0' + boolByte(r.ReadBit()))
+			}
+			if code != c.code {
+				t.Errorf("%d: code of %v have %s, want %s", order, c.value, code, c.code)
+			}
+			if x := c.read(NewBitReader(w.Buffer(), order)); x != c.value {
+				t.Errorf("%d: read have %v, want %v", order, x, c.value)
+			}
+		}
+	}
+
+	//extreme values
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		u := []uint64{0, 1, 2, 1<<63 - 1, 1 << 63, math.MaxUint64 - 1, math.MaxUint64}
+		s := []int64{0, 1, -1, math.MaxInt64, math.MinInt64 + 1, math.MinInt64}
+		w := NewBitWriter(256, order)
+		for _, x := range u {
+			w.WriteExpGolomb(x)
+			if x > 0 {
+				w.WriteEliasGamma(x)
+			}
+		}
+		for _, x := range s {
+			w.WriteSignedExpGolomb(x)
+		}
+		r := NewBitReader(w.Buffer(), order)
+		for _, x := range u {
+			if y := r.ReadExpGolomb(); y != x {
+				t.Errorf("%d: ReadExpGolomb have %d, want %d", order, y, x)
+			}
+			if x > 0 {
+				if y := r.ReadEliasGamma(); y != x {
+					t.Errorf("%d: ReadEliasGamma have %d, want %d", order, y, x)
+				}
+			}
+		}
+		for _, x := range s {
+			if y := r.ReadSignedExpGolomb(); y != x {
+				t.Errorf("%d: ReadSignedExpGolomb have %d, want %d", order, y, x)
+			}
+		}
+		if r.BitLen() != w.BitLen() {
+			t.Errorf("%d: read %d bits, but wrote %d", order, r.BitLen(), w.BitLen())
+		}
+	}
+}
+
+func TestBitStreamError(t *testing.T) {
+	recovered := func(f func()) (err error) {
+		defer func() {
+			err, _ = recover().(error)
+		}()
+		f()
+		return nil
+	}
+	r := NewBitReader([]byte{0xff, 0xff}, MSBFirst)
+	r.ReadBits(3)
+	var truncated *TruncatedError
+	if err := recovered(func() { r.ReadBits(14) }); !errors.As(err, &truncated) || truncated.Need != 2 {
+		t.Errorf("ReadBits truncated: have %v, want *TruncatedError", err)
+	}
+	if x := r.ReadBits(13); x != 1<<13-1 {
+		t.Errorf("ReadBits after error: have %x", x)
+	}
+
+	for _, f := range []func(){
+		func() { NewBitWriter(1, MSBFirst).WriteBits(0, 9) },
+		func() { NewBitWriter(9, MSBFirst).WriteBits(0, 65) },
+		func() { NewBitWriter(9, MSBFirst).WriteEliasGamma(0) },
+		func() { NewBitReader(make([]byte, 9), MSBFirst).ReadExpGolomb() },
+		func() { NewBitReader(make([]byte, 8), MSBFirst).ReadExpGolomb() },
+		func() {
+			w := NewBitWriter(32, MSBFirst)
+			w.WriteExpGolomb(math.MaxUint64)
+			NewBitReader(w.Buffer(), MSBFirst).ReadEliasGamma()
+		},
+		func() {
+			w := NewBitWriter(32, MSBFirst)
+			w.WriteExpGolomb(math.MaxUint64)
+			NewBitReader(w.Buffer(), MSBFirst).ReadSignedExpGolomb()
+		},
+	} {
+		if err := recovered(f); err == nil {
+			t.Errorf("have no error")
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type byteOrderInner struct {
+	X uint16
+	Y uint16 `binary:"le"`
+}
+
+type byteOrderHeader struct {
+	A uint16
+	B uint16            `binary:"be"`
+	C uint32            `binary:"le"`
+	D [2]uint16         `binary:"be"`
+	E byteOrderInner    `binary:"be"`
+	F []byte            `binary:"be,lenprefix=u16"`
+	G float32           `binary:"be"`
+	H *complex64        `binary:"be"`
+	I map[uint16]uint16 `binary:"be"`
+	J uint16
+}
+
+func TestByteOrder(t *testing.T) {
+	h := complex64(complex(1, 0))
+	x := byteOrderHeader{
+		A: 1, B: 2, C: 3,
+		D: [2]uint16{4, 5},
+		E: byteOrderInner{X: 6, Y: 7},
+		F: []byte{8},
+		G: 1,
+		H: &h,
+		I: map[uint16]uint16{9: 10},
+		J: 11,
+	}
+	fields := []byte{
+		0x00, 0x02, //B
+		0x03, 0x00, 0x00, 0x00, //C
+		0x02, 0x00, 0x04, 0x00, 0x05, //D
+		0x00, 0x06, 0x07, 0x00, //E
+		0x00, 0x01, 0x08, //F
+		0x3f, 0x80, 0x00, 0x00, //G
+		0x01, 0x3f, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, //H
+		0x01, 0x00, 0x09, 0x00, 0x0a, //I
+	}
+	for _, c := range []struct {
+		endian Endian
+		a, j   []byte
+	}{
+		{LittleEndian, []byte{0x01, 0x00}, []byte{0x0b, 0x00}},
+		{BigEndian, []byte{0x00, 0x01}, []byte{0x00, 0x0b}},
+	} {
+		want := append(append(append([]byte{}, c.a...), fields...), c.j...)
+		encoder := NewEncoderEndian(Sizeof(&x), c.endian)
+		if err := encoder.Value(&x); err != nil {
+			t.Fatal(err)
+		}
+		if b := encoder.Buffer(); !bytes.Equal(b, want) {
+			t.Errorf("Encode %s:\nhave %x\nwant %x", c.endian, b, want)
+		}
+		if encoder.endian != c.endian {
+			t.Errorf("Encode %s: endian of encoder changed to %s", c.endian, encoder.endian)
+		}
+		var y byteOrderHeader
+		decoder := NewDecoderEndian(want, c.endian)
+		if err := decoder.Value(&y); err != nil || !reflect.DeepEqual(y, x) {
+			t.Errorf("Decode %s: have %+v, %v", c.endian, y, err)
+		}
+		if decoder.endian != c.endian {
+			t.Errorf("Decode %s: endian of decoder changed to %s", c.endian, decoder.endian)
+		}
+
+		//skip the first element
+		s := []byteOrderHeader{x, x}
+		encoder = NewEncoderEndian(Sizeof(s)+2, c.endian)
+		if err := encoder.Value(s); err != nil {
+			t.Fatal(err)
+		}
+		encoder.Uint16(11, false)
+		var a [1]byteOrderHeader
+		var end uint16
+		decoder = NewDecoderEndian(encoder.Buffer(), c.endian)
+		if err := decoder.Value(&a); err != nil || !reflect.DeepEqual(a[0], x) {
+			t.Errorf("Decode array %s: have %+v, %v", c.endian, a[0], err)
+		}
+		if err := decoder.Value(&end); err != nil || end != 11 {
+			t.Errorf("Decode after skipped element %s: have %d, %v", c.endian, end, err)
+		}
+	}
+}
+
+func TestByteOrderError(t *testing.T) {
+	//endian is restored when decoding fails
+	var x byteOrderHeader
+	decoder := NewDecoder([]byte{0x01, 0x00, 0x00})
+	var truncated *TruncatedError
+	if err := decoder.Value(&x); !errors.As(err, &truncated) {
+		t.Errorf("Decode truncated: have %v, want *TruncatedError", err)
+	}
+	if decoder.endian != LittleEndian {
+		t.Errorf("Decode truncated: endian of decoder changed to %s", decoder.endian)
+	}
+
+	var tagErr *TagError
+	data := &struct {
+		A uint16 `binary:"be,le"`
+	}{}
+	if _, err := Encode(data, nil); !errors.As(err, &tagErr) {
+		t.Errorf("Encode %T: have %v, want *TagError", data, err)
+	}
+	if _, err := SchemaOf(reflect.TypeOf(x)); !errors.As(err, &tagErr) {
+		t.Errorf("SchemaOf: have %v, want *TagError", err)
+	}
+}
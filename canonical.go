@@ -0,0 +1,164 @@
+// canonical encoding of maps.
+
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SetCanonical enable/disable canonical mode of Encoder.
+// In canonical mode, map entries are encoded in ascending order of keys,
+// so equal values are always encoded to the same bytes.
+// Keys of ordered kinds(ints, uints, floats, strings and bools) are sorted
+// by their natural order, and the others are sorted by their encoded bytes.
+// NaN keys are before the other floats and sorted by their bits.
+// Entries of different keys that compare equal, eg: NaNs of the same bits,
+// are sorted by the encoded bytes of their values.
+// Values that serialize themselves(BinarySerializer, encoding.BinaryMarshaler)
+// are responsible for their own order.
+func (encoder *Encoder) SetCanonical(enable bool) {
+	encoder.canonical = enable
+}
+
+// SetStrict enable/disable strict mode of Decoder.
+// In strict mode, Decoder rejects map entries that are not in canonical order,
+// include duplicate keys. Keys that compare equal but are different keys of the map,
+// eg: NaNs, are not duplicate. See Encoder.SetCanonical for the canonical order.
+func (decoder *Decoder) SetStrict(enable bool) {
+	decoder.strict = enable
+}
+
+// sortEntries sort map entries in canonical order.
+func sortEntries(e mapEntries, packed bool) {
+	n := len(e.keys)
+	if n < 2 {
+		return
+	}
+	p := &entrySorter{mapEntries: e, packed: packed, values: make([][]byte, n)}
+	if !orderedKind(e.keys[0].Kind()) { //encode keys only once
+		p.keys = make([][]byte, n)
+		for i, k := range e.keys {
+			p.keys[i] = encodeKey(k, packed)
+		}
+	}
+	sort.Sort(p)
+}
+
+// entrySorter sort map entries by keys, and by values if keys are equal.
+type entrySorter struct {
+	mapEntries
+	packed bool
+	keys   [][]byte //encoded keys, if keys are not ordered kind
+	values [][]byte //encoded values, encoded only if their keys are equal
+}
+
+func (p *entrySorter) Len() int { return len(p.mapEntries.keys) }
+func (p *entrySorter) Less(i, j int) bool {
+	var c int
+	if p.keys != nil {
+		c = bytes.Compare(p.keys[i], p.keys[j])
+	} else {
+		c = compareKeys(p.mapEntries.keys[i], p.mapEntries.keys[j], p.packed)
+	}
+	if c == 0 {
+		c = bytes.Compare(p.value(i), p.value(j))
+	}
+	return c < 0
+}
+func (p *entrySorter) Swap(i, j int) {
+	e := &p.mapEntries
+	e.keys[i], e.keys[j] = e.keys[j], e.keys[i]
+	e.values[i], e.values[j] = e.values[j], e.values[i]
+	if p.keys != nil {
+		p.keys[i], p.keys[j] = p.keys[j], p.keys[i]
+	}
+	p.values[i], p.values[j] = p.values[j], p.values[i]
+}
+
+// value returns the encoded bytes of value i.
+func (p *entrySorter) value(i int) []byte {
+	if p.values[i] == nil {
+		p.values[i] = encodeKey(p.mapEntries.values[i], p.packed)
+	}
+	return p.values[i]
+}
+
+// compareKeys compare map keys a and b in canonical order.
+// It returns -1 if a < b, 0 if a == b, and +1 if a > b.
+func compareKeys(a, b reflect.Value, packed bool) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, y := a.Int(), b.Int()
+		return compareOrdered(x < y, x > y)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, y := a.Uint(), b.Uint()
+		return compareOrdered(x < y, x > y)
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		if x != x && y != y { //NaNs are sorted by bits
+			bx, by := math.Float64bits(x), math.Float64bits(y)
+			return compareOrdered(bx < by, bx > by)
+		}
+		if x != x || y != y { //NaN is less than any number
+			return compareOrdered(x != x, y != y)
+		}
+		return compareOrdered(x < y, x > y)
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Bool:
+		x, y := a.Bool(), b.Bool()
+		return compareOrdered(!x && y, x && !y)
+	}
+	return bytes.Compare(encodeKey(a, packed), encodeKey(b, packed))
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func orderedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// encodeKey returns the encoded bytes of map key or value k alone.
+func encodeKey(k reflect.Value, packed bool) []byte {
+	encoder := NewEncoderAutoGrow(minGrowSize)
+	encoder.canonical = true
+	encoder.elem(k, customOf(k.Type()), packed)
+	return encoder.Buffer()
+}
+
+// checkKey checks if map key is after the previous key in canonical order.
+// A key that compares equal to the previous one is allowed if it is not in map v yet,
+// eg: NaN, it returns true for that, and their values must be checked by checkValue.
+func (decoder *Decoder) checkKey(v, prev, key reflect.Value, packed bool) bool {
+	c := compareKeys(prev, key, packed)
+	if c > 0 || c == 0 && v.MapIndex(key).IsValid() {
+		panic(fmt.Errorf("binary.Decoder.Value: non-canonical map key %v after %v", key.Interface(), prev.Interface()))
+	}
+	return c == 0
+}
+
+// checkValue checks if map value is not before the previous value of an equal key.
+func (decoder *Decoder) checkValue(key, prev, value reflect.Value, packed bool) {
+	if bytes.Compare(encodeKey(prev, packed), encodeKey(value, packed)) > 0 {
+		panic(fmt.Errorf("binary.Decoder.Value: non-canonical map value of key %v", key.Interface()))
+	}
+}
@@ -0,0 +1,176 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type canonicalKey struct {
+	A bool
+	B string
+	C int16
+}
+
+type canonicalData struct {
+	Ints    map[int]string
+	Floats  map[float64]bool
+	Strings map[string][]uint8
+	Bools   map[bool]uint8
+	Structs map[canonicalKey]map[uint16]string
+	Ifaces  map[interface{}]int8
+}
+
+func newCanonicalData(n int) *canonicalData {
+	data := &canonicalData{
+		Ints:    make(map[int]string),
+		Floats:  make(map[float64]bool),
+		Strings: make(map[string][]uint8),
+		Bools:   map[bool]uint8{true: 1, false: 0},
+		Structs: make(map[canonicalKey]map[uint16]string),
+		Ifaces:  map[interface{}]int8{"s": 1, uint16(9): 2, nil: 3},
+	}
+	for i := 0; i < n; i++ {
+		data.Ints[i*7-n] = string(rune('a' + i%26))
+		data.Floats[float64(i)/3-1] = i%2 == 0
+		data.Strings[string(rune('A'+i))] = []uint8{uint8(i)}
+		data.Structs[canonicalKey{i%2 == 0, string(rune('z' - i%26)), int16(-i)}] = map[uint16]string{uint16(i): "x", uint16(i + 300): "y"}
+	}
+	data.Floats[math.Inf(-1)] = true
+	return data
+}
+
+func TestEncodeCanonical(t *testing.T) {
+	data := newCanonicalData(50)
+	b, err := EncodeCanonical(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := Sizeof(data); size != len(b) {
+		t.Errorf("EncodeCanonical got size %d, need %d", len(b), size)
+	}
+	for i := 0; i < 10; i++ {
+		b2, err := EncodeCanonical(newCanonicalData(50), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, b2) {
+			t.Fatalf("EncodeCanonical got different bytes\n%x\n%x", b, b2)
+		}
+	}
+
+	for _, bufSize := range []int{1, 7, 64} {
+		w := &chunkWriter{}
+		encoder := NewStreamEncoder(w, DefaultEndian)
+		encoder.buff = make([]byte, bufSize)
+		encoder.SetCanonical(true)
+		if err := encoder.Value(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := encoder.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.Bytes(), b) {
+			t.Errorf("stream canonical buffer %d got\n%x\nneed\n%x", bufSize, w.Bytes(), b)
+		}
+	}
+
+	decoder := NewDecoder(b)
+	decoder.SetStrict(true)
+	var r canonicalData
+	if err := decoder.Value(&r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&r, data) {
+		t.Errorf("EncodeCanonical got %#v\nneed %#v", r, data)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	cases := []struct {
+		b  []byte
+		ok bool
+	}{
+		{[]byte{3, 1, 0, 2, 0, 3, 0}, true},
+		{[]byte{3, 1, 0, 3, 0, 2, 0}, false}, //unsorted
+		{[]byte{3, 1, 0, 2, 0, 2, 0}, false}, //duplicate
+	}
+	for i, c := range cases {
+		var m map[uint8]uint8
+		if err := Decode(c.b, &m); err != nil {
+			t.Errorf("case %d: %v", i, err)
+		}
+
+		decoder := NewDecoder(c.b)
+		decoder.SetStrict(true)
+		m = nil
+		if err := decoder.Value(&m); (err == nil) != c.ok {
+			t.Errorf("case %d: strict decode have err %v, want ok=%v", i, err, c.ok)
+		}
+
+		var a [0]map[uint8]uint8 //skipped map is checked too
+		decoder = NewDecoder(append([]byte{1}, c.b...))
+		decoder.SetStrict(true)
+		if err := decoder.Value(&a); (err == nil) != c.ok {
+			t.Errorf("case %d: strict skip have err %v, want ok=%v", i, err, c.ok)
+		}
+	}
+
+	func() { //error of skipped map is not lost
+		defer func() {
+			var e *UnsupportedTypeError
+			if err, _ := recover().(error); !errors.As(err, &e) {
+				t.Errorf("strict skip unsupported map: have %v, want *UnsupportedTypeError", err)
+			}
+		}()
+		decoder := NewDecoder([]byte{1, 0})
+		decoder.SetStrict(true)
+		decoder.skipByType(reflect.TypeOf(map[uint8]func(){}), false)
+	}()
+}
+
+func TestCanonicalEqualKeys(t *testing.T) {
+	nan, nan2 := math.NaN(), math.Float64frombits(0x7ff8000000000002)
+	x, y := 1, 1
+	cases := []interface{}{
+		map[float64]int8{nan: 1, nan2: 2, math.Inf(-1): 3, 0: 4},
+		map[float64]int8{nan: 3, nan: 1, nan: 2, 1: 0}, //NaNs of the same bits
+		map[float32]string{float32(nan): "b", float32(nan): "a"},
+		map[*int]int8{&x: 2, &y: 1}, //different pointers of equal values
+	}
+	for i, c := range cases {
+		b, err := EncodeCanonical(c, nil)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		if size := Sizeof(c); size != len(b) {
+			t.Errorf("case %d: have size %d, want %d", i, len(b), size)
+		}
+		for j := 0; j < 10; j++ {
+			if b2, _ := EncodeCanonical(c, nil); !bytes.Equal(b, b2) {
+				t.Fatalf("case %d: EncodeCanonical got different bytes\n%x\n%x", i, b, b2)
+			}
+		}
+		r := reflect.New(reflect.TypeOf(c))
+		decoder := NewDecoder(b)
+		decoder.SetStrict(true)
+		if err := decoder.Value(r.Interface()); err != nil || r.Elem().Len() != reflect.ValueOf(c).Len() {
+			t.Errorf("case %d: strict decode have %v, %v", i, r.Elem().Interface(), err)
+		}
+	}
+
+	encoder := NewEncoderAutoGrow(0) //values of equal keys are unsorted
+	encoder.Uvarint(2)
+	encoder.Float64(nan)
+	encoder.Int8(2)
+	encoder.Float64(nan)
+	encoder.Int8(1)
+	var m map[float64]int8
+	decoder := NewDecoder(encoder.Buffer())
+	decoder.SetStrict(true)
+	if err := decoder.Value(&m); err == nil {
+		t.Errorf("unsorted values of NaN keys: have err == nil")
+	}
+}
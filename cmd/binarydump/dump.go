@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/vipally/binary"
+)
+
+// Dump writes annotated hex dump of data to w.
+// If typeName is empty, data is self-describing,
+// otherwise it's schema is found in registry by Lookup.
+func Dump(w io.Writer, data []byte, registry []byte, typeName string) error {
+	var s *binary.Schema
+	if typeName != "" {
+		var err error
+		if s, err = Lookup(registry, typeName); err != nil {
+			return err
+		}
+	}
+	return binary.Dump(w, data, s)
+}
+
+// schemas decode all schemas of registry.
+func schemas(registry []byte) ([]*binary.Schema, error) {
+	var r []*binary.Schema
+	for offset := 0; offset < len(registry); {
+		s, n, err := binary.DecodeSchema(registry[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("registry at offset %d: %v", offset, err)
+		}
+		r = append(r, s)
+		offset += n
+	}
+	return r, nil
+}
+
+// Names returns names of types in registry.
+func Names(registry []byte) ([]string, error) {
+	all, err := schemas(registry)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for _, s := range all {
+		names = append(names, s.Name)
+	}
+	return names, nil
+}
+
+// Lookup returns schema of type name in registry.
+// name matches the full name with package path, or the end of it after '/' or '.',
+// it must match only one type.
+func Lookup(registry []byte, name string) (*binary.Schema, error) {
+	all, err := schemas(registry)
+	if err != nil {
+		return nil, err
+	}
+	var found *binary.Schema
+	for _, s := range all {
+		if s.Name == name {
+			return s, nil
+		}
+		if strings.HasSuffix(s.Name, "/"+name) || strings.HasSuffix(s.Name, "."+name) {
+			if found != nil {
+				return nil, fmt.Errorf("ambiguous type %s: %s and %s", name, found.Name, s.Name)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("type %s is not in registry", name)
+	}
+	return found, nil
+}
+
+// ParseHex parse hex text, spaces are ignored.
+func ParseHex(text string) ([]byte, error) {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+	return hex.DecodeString(text)
+}
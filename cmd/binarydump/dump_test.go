@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vipally/binary"
+)
+
+type Point struct {
+	X int16
+	Y int16
+	P *Point2
+	B bool
+}
+
+type Point2 struct {
+	Z uint32 `binary:"packed"`
+}
+
+func registryOf(t *testing.T, types ...interface{}) []byte {
+	var r []byte
+	for _, x := range types {
+		s, err := binary.SchemaOf(reflect.TypeOf(x))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = binary.AppendSchema(r, s)
+	}
+	return r
+}
+
+func TestDump(t *testing.T) {
+	r := registryOf(t, Point{}, Point2{})
+	names, err := Names(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"github.com/vipally/binary/cmd/binarydump.Point", "github.com/vipally/binary/cmd/binarydump.Point2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Names: have %v, want %v", names, want)
+	}
+
+	b, err := binary.Encode(Point{X: 1, Y: -2, P: &Point2{Z: 3}, B: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	for i, c := range b {
+		if i%4 == 0 {
+			text += "\n"
+		}
+		text += fmt.Sprintf(" %02X", c)
+	}
+	data, err := ParseHex(text)
+	if err != nil || !bytes.Equal(data, b) {
+		t.Fatalf("ParseHex(%q): have %x, %v, want %x", text, data, err, b)
+	}
+
+	for _, name := range []string{"Point", "binarydump.Point", names[0]} {
+		var w bytes.Buffer
+		if err := Dump(&w, data, r, name); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			"binarydump.Point.X  int16 1",
+			"binarydump.Point.P (nil flag)  not nil (bit 0 of 03)",
+			"binarydump.Point.P.Z  uint32 varint 3",
+			"binarydump.Point.B  bool true (bit 1 of 03)",
+		} {
+			if !strings.Contains(w.String(), want) {
+				t.Errorf("Dump %s: missing %q in dump:\n%s", name, want, w.String())
+			}
+		}
+	}
+
+	//self-describing
+	b, err = binary.EncodeDescribed(Point2{Z: 3}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w bytes.Buffer
+	if err := Dump(&w, b, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := "binarydump.Point2.Z  uint32 varint 3"; !strings.Contains(w.String(), want) {
+		t.Errorf("missing %q in dump:\n%s", want, w.String())
+	}
+}
+
+func TestLookupError(t *testing.T) {
+	r := registryOf(t, Point{}, Point2{})
+	if _, err := Lookup(r, "Point3"); err == nil {
+		t.Error("Lookup Point3: have err == nil, want non-nil")
+	}
+	if _, err := Lookup(append(r, r...), "Point"); err == nil {
+		t.Error("Lookup ambiguous Point: have err == nil, want non-nil")
+	}
+	if _, err := Lookup(r[:len(r)-1], "Point"); err == nil {
+		t.Error("Lookup in truncated registry: have err == nil, want non-nil")
+	}
+}
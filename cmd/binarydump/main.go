@@ -0,0 +1,105 @@
+// Binarydump prints annotated hex dump of data encoded by package github.com/vipally/binary.
+// Every line is offset, number of bytes, the bytes, path and decoded value of a value,
+// including lengths, bool bit positions, nil flags of pointers and ids of versioned fields.
+//
+// Usage:
+//
+//	binarydump [-registry file -type T] [-hex] [file]
+//
+// It reads data from file, or hex text from standard input if file is not given.
+//
+// Self-describing data encoded by binary.EncodeDescribed is dumped by it's embedded schema.
+// Other data needs a registry file, which is a sequence of schemas appended by binary.AppendSchema, eg:
+//
+//	var registry []byte
+//	for _, x := range []interface{}{Order{}, Item{}} {
+//		s, _ := binary.SchemaOf(reflect.TypeOf(x))
+//		registry = binary.AppendSchema(registry, s)
+//	}
+//	ioutil.WriteFile("types.schema", registry, 0644)
+//
+// and the type of data is selected by -type with it's name,
+// such as Order, main.Order or the full name with package path.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+var (
+	registry = flag.String("registry", "", "file of schemas written by binary.AppendSchema; default use schema of self-describing data")
+	typeName = flag.String("type", "", "name of the type in registry")
+	hexInput = flag.Bool("hex", false, "file is hex text; always true for standard input")
+	list     = flag.Bool("list", false, "list type names in registry")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of binarydump:\n")
+	fmt.Fprintf(os.Stderr, "\tbinarydump [-registry file -type T] [-hex] [file]\n")
+	fmt.Fprintf(os.Stderr, "\tbinarydump -registry file -list\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// validFlags returns if the flags and args are valid.
+func validFlags() bool {
+	switch {
+	case flag.NArg() > 1:
+		return false
+	case *list:
+		return *registry != "" && *typeName == "" && flag.NArg() == 0
+	}
+	return (*registry == "") == (*typeName == "")
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("binarydump: ")
+	flag.Usage = usage
+	flag.Parse()
+	if !validFlags() {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var schemas []byte
+	if *registry != "" {
+		b, err := ioutil.ReadFile(*registry)
+		if err != nil {
+			log.Fatal(err)
+		}
+		schemas = b
+	}
+	if *list {
+		names, err := Names(schemas)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	var data []byte
+	var err error
+	if flag.NArg() == 1 {
+		data, err = ioutil.ReadFile(flag.Arg(0))
+	} else {
+		data, err = ioutil.ReadAll(os.Stdin)
+		*hexInput = true
+	}
+	if err == nil && *hexInput {
+		data, err = ParseHex(string(data))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := Dump(os.Stdout, data, schemas, *typeName); err != nil {
+		log.Fatal(err)
+	}
+}
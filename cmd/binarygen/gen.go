@@ -0,0 +1,753 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const binaryPath = "github.com/vipally/binary"
+
+// Generate returns the formatted source of methods for types names of
+// the package in dir. File skip is excluded from the package, it is the
+// output file that will be replaced.
+func Generate(dir string, names []string, skip string) ([]byte, error) {
+	pkg, err := loadPackage(dir, skip)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{pkg: pkg, imports: map[string]string{binaryPath: "binary"}, names: map[string]bool{}}
+	for _, name := range names {
+		g.names[name] = true
+	}
+	for _, name := range names {
+		if err := g.generate(name); err != nil {
+			return nil, err
+		}
+	}
+	return g.source(names)
+}
+
+// loadPackage parse and type check the package in dir.
+// Type check errors are ignored, because the methods to generate may be
+// used by the package already.
+func loadPackage(dir string, skip string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		if name == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) {},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, fset, files, nil)
+	if pkg == nil {
+		return nil, fmt.Errorf("type check package %s fail", dir)
+	}
+	return pkg, nil
+}
+
+type generator struct {
+	pkg     *types.Package
+	imports map[string]string //import path -> package name
+	buf     bytes.Buffer
+	tmp     int             //number of temporary variables
+	stack   []*types.Named  //named struct types being generated
+	names   map[string]bool //names of types to generate methods for
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) newVar(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	g.imports[p.Path()] = p.Name()
+	return p.Name()
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+// source returns the formatted source of generated file.
+func (g *generator) source(names []string) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"binarygen -type %s\"; DO NOT EDIT.\n\n", strings.Join(names, ","))
+	fmt.Fprintf(&b, "package %s\n\n", g.pkg.Name())
+
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	b.WriteString("import (\n")
+	for _, path := range paths {
+		if name := g.imports[path]; name != filepath.Base(path) {
+			fmt.Fprintf(&b, "%s %q\n", name, path)
+		} else {
+			fmt.Fprintf(&b, "%q\n", path)
+		}
+	}
+	b.WriteString(")\n\n")
+
+	b.Write(g.buf.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code fail: %v\n%s", err, b.Bytes())
+	}
+	return src, nil
+}
+
+// generate Size/Encode/Decode methods for type name.
+// Fields of the type itself are generated, so it is not taken as custom.
+func (g *generator) generate(name string) error {
+	obj, ok := g.pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("type %s not found in package %s", name, g.pkg.Name())
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return fmt.Errorf("type %s is not a named type", name)
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return fmt.Errorf("type %s is not a struct", name)
+	}
+
+	g.tmp = 0
+	g.printf("// Size implements binary.BinarySizer.\n")
+	g.printf("func (obj *%s) Size() int {\n", name)
+	g.printf("size, bools := 0, 0\n")
+	if err := g.size("obj", named.Underlying(), false); err != nil {
+		return err
+	}
+	g.printf("return size + (bools+7)/8\n")
+	g.printf("}\n\n")
+
+	g.tmp = 0
+	g.printf("// Encode implements binary.BinaryEncoder.\n")
+	g.printf("func (obj *%s) Encode(buffer []byte) ([]byte, error) {\n", name)
+	g.printf("buff, err := binary.MakeEncodeBuffer(obj, buffer)\n")
+	g.printf("if err != nil {\nreturn nil, err\n}\n")
+	g.printf("encoder := binary.NewEncoderBuffer(buff)\n")
+	if err := g.encode("obj", named.Underlying(), false); err != nil {
+		return err
+	}
+	g.printf("return encoder.Buffer(), nil\n")
+	g.printf("}\n\n")
+
+	g.tmp = 0
+	g.printf("// Decode implements binary.BinaryDecoder.\n")
+	g.printf("func (obj *%s) Decode(buffer []byte) (err error) {\n", name)
+	g.printf("defer func() {\n")
+	g.printf("if info := recover(); info != nil {\n")
+	g.printf("if err, _ = info.(error); err == nil {\npanic(info)\n}\n")
+	g.printf("}\n")
+	g.printf("}()\n\n")
+	g.printf("decoder := binary.NewDecoder(buffer)\n")
+	if err := g.decode("obj", named.Underlying(), false); err != nil {
+		return err
+	}
+	g.printf("return nil\n")
+	g.printf("}\n\n")
+	return nil
+}
+
+// body generate statements by f into a separated buffer.
+func (g *generator) body(f func() error) (string, error) {
+	old := g.buf
+	g.buf = bytes.Buffer{}
+	err := f()
+	s := g.buf.String()
+	g.buf = old
+	return s, err
+}
+
+// loopVar returns v if it is used in body, or "_".
+func loopVar(v string, body string) string {
+	if regexp.MustCompile(`\b` + v + `\b`).MatchString(body) {
+		return v
+	}
+	return "_"
+}
+
+// rangeLoop generate a for range loop of x with body generated by f.
+func (g *generator) rangeLoop(x string, k, v string, f func() error) error {
+	body, err := g.body(f)
+	if err != nil {
+		return err
+	}
+	k, v = loopVar(k, body), loopVar(v, body)
+	switch {
+	case v != "_":
+		g.printf("for %s, %s := range %s {\n", k, v, x)
+	case k != "_":
+		g.printf("for %s := range %s {\n", k, x)
+	default:
+		g.printf("for range %s {\n", x)
+	}
+	g.buf.WriteString(body)
+	g.printf("}\n")
+	return nil
+}
+
+// fields calls f for every encoding field of struct t.
+// Unexported fields and fields with tag `binary:"ignore"` are ignored.
+func (g *generator) fields(t types.Type, f func(field *types.Var, packed bool) error) error {
+	if named, ok := t.(*types.Named); ok {
+		for _, n := range g.stack {
+			if n == named {
+				return fmt.Errorf("recursive type %s is not supported", named.String())
+			}
+		}
+		g.stack = append(g.stack, named)
+		defer func() { g.stack = g.stack[:len(g.stack)-1] }()
+	}
+
+	st := t.Underlying().(*types.Struct)
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		ignore, packed, unsupported := fieldTag(st.Tag(i))
+		if !field.Exported() || ignore {
+			continue
+		}
+		if unsupported != "" {
+			return fmt.Errorf("%s %s.%s is not supported", unsupported, t.String(), field.Name())
+		}
+		if err := f(field, packed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldTag returns the options of field tag `binary:"opt1,opt2,..."`.
+// unsupported describes the field if an option that is not supported is used.
+func fieldTag(tag string) (ignore, packed bool, unsupported string) {
+	for _, opt := range strings.Split(reflect.StructTag(tag).Get("binary"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch name {
+		case "ignore":
+			ignore = true
+		case "packed":
+			packed = true
+		case "id", "default":
+			unsupported = "versioned struct field"
+		case "len", "lenprefix", "lenfield":
+			unsupported = "length tag of field"
+		case "be", "le":
+			unsupported = "byte order tag of field"
+		case "bits":
+			unsupported = "bit field"
+		}
+	}
+	return
+}
+
+// basic returns the method name and go type of basic kind.
+func basic(b *types.Basic) (method string, goType string, size int) {
+	switch b.Kind() {
+	case types.Bool:
+		return "Bool", "bool", 0
+	case types.Int:
+		return "Int", "int", 0
+	case types.Uint:
+		return "Uint", "uint", 0
+	case types.Int8:
+		return "Int8", "int8", 1
+	case types.Uint8:
+		return "Uint8", "uint8", 1
+	case types.Int16:
+		return "Int16", "int16", 2
+	case types.Uint16:
+		return "Uint16", "uint16", 2
+	case types.Int32:
+		return "Int32", "int32", 4
+	case types.Uint32:
+		return "Uint32", "uint32", 4
+	case types.Int64:
+		return "Int64", "int64", 8
+	case types.Uint64:
+		return "Uint64", "uint64", 8
+	case types.Float32:
+		return "Float32", "float32", 4
+	case types.Float64:
+		return "Float64", "float64", 8
+	case types.Complex64:
+		return "Complex64", "complex64", 8
+	case types.Complex128:
+		return "Complex128", "complex128", 16
+	case types.String:
+		return "String", "string", 0
+	}
+	return "", "", 0
+}
+
+// stdTypes are standard library types that binary encodes by special codec.
+// time.Duration is encoded as varint, the others are not supported yet.
+var stdTypes = map[string]bool{
+	"time.Time":        true,
+	"time.Duration":    true,
+	"math/big.Int":     true,
+	"math/big.Float":   true,
+	"math/big.Rat":     true,
+	"net/netip.Addr":   true,
+	"net/netip.Prefix": true,
+	"net/url.URL":      true,
+}
+
+// stdType returns the full name of t if it is a standard library type in stdTypes.
+func stdType(t types.Type) string {
+	if named, ok := t.(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil {
+			if name := obj.Pkg().Path() + "." + obj.Name(); stdTypes[name] {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// custom returns how t serializes itself, "Serializer" for binary.BinarySerializer,
+// "Marshaler" for encoding.BinaryMarshaler/BinaryUnmarshaler, or "" for neither.
+// Types to generate methods for are serializers.
+// Nested custom values are encoded as length-prefixed bytes of their own.
+func (g *generator) custom(t types.Type) string {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return ""
+	}
+	if stdType(t) != "" {
+		return ""
+	}
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() == g.pkg && g.names[named.Obj().Name()] {
+		return "Serializer"
+	}
+	ms := types.NewMethodSet(types.NewPointer(t))
+	has := func(name string) bool {
+		return ms.Lookup(nil, name) != nil
+	}
+	switch {
+	case has("Size") && has("Encode") && has("Decode"):
+		return "Serializer"
+	case has("MarshalBinary") && has("UnmarshalBinary"):
+		return "Marshaler"
+	}
+	return ""
+}
+
+// method returns the expression to call method of x.
+func method(x string, name string) string {
+	if strings.HasPrefix(x, "*") {
+		x = "(" + x + ")"
+	}
+	return x + "." + name
+}
+
+// packable reports if basic kind b can be encoded as varint by tag packed.
+func packable(b *types.Basic) bool {
+	switch b.Kind() {
+	case types.Int16, types.Int32, types.Int64, types.Uint16, types.Uint32, types.Uint64:
+		return true
+	}
+	return false
+}
+
+// fixedSize returns encoded size of t if it is fixed, or 0.
+func (g *generator) fixedSize(t types.Type, packed bool) int {
+	if stdType(t) != "" || g.custom(t) != "" {
+		return 0
+	}
+	if b, ok := t.Underlying().(*types.Basic); ok && !(packed && packable(b)) {
+		_, _, size := basic(b)
+		return size
+	}
+	return 0
+}
+
+// minBits returns the minimum number of bits to encode a value of type t.
+func (g *generator) minBits(t types.Type, packed bool) int {
+	if stdType(t) != "" || g.custom(t) != "" {
+		return 8
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if u.Kind() == types.Bool {
+			return 1
+		}
+		if size := g.fixedSize(t, packed); size > 0 {
+			return size * 8
+		}
+	case *types.Pointer: //nil flag
+		return 1
+	case *types.Struct:
+		sum := 0
+		for i := 0; i < u.NumFields(); i++ {
+			ignore, packed, _ := fieldTag(u.Tag(i))
+			if field := u.Field(i); field.Exported() && !ignore {
+				sum += g.minBits(field.Type(), packed)
+			}
+		}
+		return sum
+	}
+	return 8 //varint, string or length
+}
+
+func isBool(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Bool
+}
+
+// convert returns x converted to goType if t is a named type.
+func convert(goType string, t types.Type, x string) string {
+	if _, ok := t.(*types.Basic); ok {
+		return x
+	}
+	return goType + "(" + x + ")"
+}
+
+// deref returns the expression of value that pointer x points to.
+func deref(x string, elem types.Type) string {
+	switch elem.Underlying().(type) {
+	case *types.Struct: //selector auto dereference
+		return x
+	case *types.Basic:
+		return "*" + x
+	}
+	return "(*" + x + ")"
+}
+
+func unsupported(t types.Type) error {
+	return fmt.Errorf("unsupported type %s", t.String())
+}
+
+// std checks if t is a standard library type, and generate statements by
+// f for time.Duration. It returns error for the other standard library types.
+func std(t types.Type, f func()) (bool, error) {
+	switch stdType(t) {
+	case "":
+		return false, nil
+	case "time.Duration":
+		f()
+		return true, nil
+	}
+	return true, unsupported(t)
+}
+
+// size generate statements to count size of x with type t.
+func (g *generator) size(x string, t types.Type, packed bool) error {
+	if ok, err := std(t, func() {
+		g.printf("size += binary.SizeofVarint(int64(%s))\n", x)
+	}); ok {
+		return err
+	}
+	switch g.custom(t) {
+	case "Serializer":
+		n := g.newVar("s")
+		g.printf("%s := %s()\n", n, method(x, "Size"))
+		g.printf("size += binary.SizeofUvarint(uint64(%s)) + %s\n", n, n)
+		return nil
+	case "Marshaler":
+		b := g.newVar("b")
+		g.printf("%s, _ := %s()\n", b, method(x, "MarshalBinary"))
+		g.printf("size += binary.SizeofUvarint(uint64(len(%s))) + len(%s)\n", b, b)
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		method, goType, size := basic(u)
+		switch {
+		case method == "":
+			return unsupported(t)
+		case method == "Bool":
+			g.printf("bools++\n")
+		case method == "String":
+			g.printf("size += binary.SizeofUvarint(uint64(len(%s))) + len(%s)\n", x, x)
+		case method == "Int" || packed && packable(u) && strings.HasPrefix(method, "Int"):
+			g.printf("size += binary.SizeofVarint(int64(%s))\n", x)
+		case method == "Uint" || packed && packable(u):
+			g.printf("size += binary.SizeofUvarint(uint64(%s))\n", x)
+		default:
+			g.printf("size += %d //%s\n", size, goType)
+		}
+
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Pointer); ok {
+			return unsupported(t)
+		}
+		g.printf("bools++ //nil flag\n")
+		g.printf("if %s != nil {\n", x)
+		if err := g.size(deref(x, u.Elem()), u.Elem(), packed); err != nil {
+			return err
+		}
+		g.printf("}\n")
+
+	case *types.Slice, *types.Array:
+		elem := u.(interface{ Elem() types.Type }).Elem()
+		if isBool(elem) {
+			g.printf("size += binary.SizeofUvarint(uint64(len(%s))) + (len(%s)+7)/8\n", x, x)
+			break
+		}
+		g.printf("size += binary.SizeofUvarint(uint64(len(%s)))\n", x)
+		if size := g.fixedSize(elem, packed); size > 0 {
+			g.printf("size += len(%s) * %d\n", x, size)
+			break
+		}
+		i := g.newVar("i")
+		return g.rangeLoop(x, i, "_", func() error {
+			return g.size(x+"["+i+"]", elem, packed)
+		})
+
+	case *types.Map:
+		g.printf("size += binary.SizeofUvarint(uint64(len(%s)))\n", x)
+		k, v := g.newVar("k"), g.newVar("v")
+		return g.rangeLoop(x, k, v, func() error {
+			if err := g.size(k, u.Key(), packed); err != nil {
+				return err
+			}
+			return g.size(v, u.Elem(), packed)
+		})
+
+	case *types.Struct:
+		return g.fields(t, func(field *types.Var, packed bool) error {
+			return g.size(x+"."+field.Name(), field.Type(), packed)
+		})
+
+	default:
+		return unsupported(t)
+	}
+	return nil
+}
+
+// encode generate statements to encode x with type t.
+func (g *generator) encode(x string, t types.Type, packed bool) error {
+	if ok, err := std(t, func() {
+		g.printf("encoder.Varint(int64(%s))\n", x)
+	}); ok {
+		return err
+	}
+	if kind := g.custom(t); kind != "" {
+		b := g.newVar("b")
+		if kind == "Serializer" {
+			g.printf("%s, err := %s(nil)\n", b, method(x, "Encode"))
+		} else {
+			g.printf("%s, err := %s()\n", b, method(x, "MarshalBinary"))
+		}
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("encoder.String(string(%s))\n", b)
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		method, goType, _ := basic(u)
+		switch {
+		case method == "":
+			return unsupported(t)
+		case packable(u):
+			g.printf("encoder.%s(%s, %v)\n", method, convert(goType, t, x), packed)
+		default:
+			g.printf("encoder.%s(%s)\n", method, convert(goType, t, x))
+		}
+
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Pointer); ok {
+			return unsupported(t)
+		}
+		g.printf("if %s != nil {\n", x)
+		g.printf("encoder.Bool(true)\n")
+		if err := g.encode(deref(x, u.Elem()), u.Elem(), packed); err != nil {
+			return err
+		}
+		g.printf("} else {\n")
+		g.printf("encoder.Bool(false)\n")
+		g.printf("}\n")
+
+	case *types.Slice, *types.Array:
+		elem := u.(interface{ Elem() types.Type }).Elem()
+		g.printf("encoder.Uvarint(uint64(len(%s)))\n", x)
+		i := g.newVar("i")
+		if isBool(elem) { //bool array use it's own bytes
+			b, e := g.newVar("b"), g.newVar("e")
+			g.printf("var %s uint8\n", b)
+			g.printf("for %s, %s := range %s {\n", i, e, x)
+			g.printf("if %s {\n%s |= 1 << uint(%s%%8)\n}\n", e, b, i)
+			g.printf("if %s%%8 == 7 || %s == len(%s)-1 {\n", i, i, x)
+			g.printf("encoder.Uint8(%s)\n%s = 0\n", b, b)
+			g.printf("}\n")
+			g.printf("}\n")
+			break
+		}
+		return g.rangeLoop(x, i, "_", func() error {
+			return g.encode(x+"["+i+"]", elem, packed)
+		})
+
+	case *types.Map:
+		g.printf("encoder.Uvarint(uint64(len(%s)))\n", x)
+		k, v := g.newVar("k"), g.newVar("v")
+		return g.rangeLoop(x, k, v, func() error {
+			if err := g.encode(k, u.Key(), packed); err != nil {
+				return err
+			}
+			return g.encode(v, u.Elem(), packed)
+		})
+
+	case *types.Struct:
+		return g.fields(t, func(field *types.Var, packed bool) error {
+			return g.encode(x+"."+field.Name(), field.Type(), packed)
+		})
+
+	default:
+		return unsupported(t)
+	}
+	return nil
+}
+
+// decode generate statements to decode x with type t.
+func (g *generator) decode(x string, t types.Type, packed bool) error {
+	if ok, err := std(t, func() {
+		v := g.newVar("d")
+		g.printf("%s, _ := decoder.Varint()\n", v)
+		g.printf("%s = %s(%s)\n", x, g.typeString(t), v)
+	}); ok {
+		return err
+	}
+	if kind := g.custom(t); kind != "" {
+		name := "Decode"
+		if kind == "Marshaler" {
+			name = "UnmarshalBinary"
+		}
+		g.printf("if err := %s([]byte(decoder.String())); err != nil {\nreturn err\n}\n", method(x, name))
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		method, _, _ := basic(u)
+		value := ""
+		switch {
+		case method == "":
+			return unsupported(t)
+		case packable(u):
+			value = fmt.Sprintf("decoder.%s(%v)", method, packed)
+		default:
+			value = fmt.Sprintf("decoder.%s()", method)
+		}
+		if _, ok := t.(*types.Basic); !ok {
+			value = g.typeString(t) + "(" + value + ")"
+		}
+		g.printf("%s = %s\n", x, value)
+
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Pointer); ok {
+			return unsupported(t)
+		}
+		g.printf("if decoder.Bool() {\n")
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", x, x, g.typeString(u.Elem()))
+		if err := g.decode(deref(x, u.Elem()), u.Elem(), packed); err != nil {
+			return err
+		}
+		g.printf("} else {\n")
+		g.printf("%s = nil\n", x)
+		g.printf("}\n")
+
+	case *types.Slice, *types.Array:
+		elem := u.(interface{ Elem() types.Type }).Elem()
+		_, isArray := u.(*types.Array)
+		n, i := g.newVar("n"), g.newVar("i")
+		g.printf("%s := decoder.SliceLen(%d)\n", n, g.minBits(elem, packed))
+		if !isArray {
+			g.printf("if %s > 0 {\n%s = make(%s, %s)\n}\n", n, x, g.typeString(t), n)
+		}
+		if isBool(elem) { //bool array use it's own bytes
+			b := g.newVar("b")
+			g.printf("var %s uint8\n", b)
+			g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+			g.printf("if %s%%8 == 0 {\n%s = decoder.Uint8()\n}\n", i, b)
+			value := fmt.Sprintf("%s&(1<<uint(%s%%8)) != 0", b, i)
+			if _, ok := elem.(*types.Basic); !ok {
+				value = g.typeString(elem) + "(" + value + ")"
+			}
+			if isArray {
+				g.printf("if %s < len(%s) {\n%s[%s] = %s\n}\n", i, x, x, i, value)
+			} else {
+				g.printf("%s[%s] = %s\n", x, i, value)
+			}
+			g.printf("}\n")
+			break
+		}
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		if isArray { //skip elements out of array
+			g.printf("if %s >= len(%s) {\n", i, x)
+			tmp := g.newVar("t")
+			g.printf("var %s %s\n", tmp, g.typeString(elem))
+			if err := g.decode(tmp, elem, packed); err != nil {
+				return err
+			}
+			g.printf("_ = %s\n", tmp)
+			g.printf("continue\n")
+			g.printf("}\n")
+		}
+		if err := g.decode(x+"["+i+"]", elem, packed); err != nil {
+			return err
+		}
+		g.printf("}\n")
+
+	case *types.Map:
+		n, i, k, v := g.newVar("n"), g.newVar("i"), g.newVar("k"), g.newVar("v")
+		g.printf("if %s == nil {\n%s = make(%s)\n}\n", x, x, g.typeString(t))
+		g.printf("%s := decoder.MapLen(%d)\n", n, g.minBits(u.Key(), packed)+g.minBits(u.Elem(), packed))
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.printf("var %s %s\n", k, g.typeString(u.Key()))
+		g.printf("var %s %s\n", v, g.typeString(u.Elem()))
+		if err := g.decode(k, u.Key(), packed); err != nil {
+			return err
+		}
+		if err := g.decode(v, u.Elem(), packed); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n", x, k, v)
+		g.printf("}\n")
+
+	case *types.Struct:
+		return g.fields(t, func(field *types.Var, packed bool) error {
+			return g.decode(x+"."+field.Name(), field.Type(), packed)
+		})
+
+	default:
+		return unsupported(t)
+	}
+	return nil
+}
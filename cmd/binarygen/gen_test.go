@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestGolden checks that the generated code of package sample is up to date.
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("internal", "sample")
+	src, err := Generate(dir, []string{"Message", "Packed", "Short"}, "message_binary.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := ioutil.ReadFile(filepath.Join(dir, "message_binary.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, golden) {
+		t.Errorf("generated code of %s is out of date, run go generate", dir)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	dir := filepath.Join("testdata", "bad")
+	for _, name := range []string{"Iface", "Chan", "Uintptr", "PPointer", "Recursive", "NotStruct", "NotExist", "Time", "Versioned", "Length", "ByteOrder", "Bits"} {
+		if _, err := Generate(dir, []string{name}, ""); err == nil {
+			t.Errorf("Generate %s: have err == nil, want non-nil", name)
+		}
+	}
+}
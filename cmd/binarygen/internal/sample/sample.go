@@ -0,0 +1,102 @@
+// Package sample is used to test the code generated by binarygen.
+package sample
+
+import (
+	"errors"
+	"time"
+)
+
+//go:generate go run github.com/vipally/binary/cmd/binarygen -type Message,Packed,Short
+
+// Level is a named integer type.
+type Level int8
+
+// Tags is a named slice type.
+type Tags []string
+
+// Point is a nested struct type.
+type Point struct {
+	X, Y int32
+}
+
+// Version implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+type Version struct {
+	Major, Minor uint8
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (v Version) MarshalBinary() ([]byte, error) {
+	return []byte{v.Major, v.Minor}, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (v *Version) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return errors.New("sample: invalid version")
+	}
+	v.Major, v.Minor = b[0], b[1]
+	return nil
+}
+
+// Message contains all kinds of supported fields.
+type Message struct {
+	ID     uint64
+	Name   string
+	Ok     bool
+	Level  Level
+	Count  int
+	Index  uint
+	Small  int8
+	U8     uint8
+	I16    int16
+	U16    uint16
+	F32    float32
+	F64    float64
+	C64    complex64
+	C128   complex128
+	Bytes  []byte
+	Flags  []bool
+	Fixed  [3]bool
+	Arr    [2]Point
+	Points []Point
+	PPoint *Point
+	PNil   *Point
+	PStr   *string
+	Tags   Tags
+	Map    map[string]*Point
+	Wait   time.Duration
+	Waits  []time.Duration
+	Ver    Version
+	Vers   map[string]Version
+	Short  *Short
+	Shorts []Short
+	Nested struct {
+		A bool
+		B []uint16
+	}
+	Ignored int `binary:"ignore"`
+	private int
+	Last    bool
+	Point
+}
+
+// Packed contains fields with tag `binary:"packed"`.
+type Packed struct {
+	A int16            `binary:"packed"`
+	B int32            `binary:"packed"`
+	C int64            `binary:"packed"`
+	D uint16           `binary:"packed"`
+	E uint32           `binary:"packed"`
+	F uint64           `binary:"packed"`
+	G []uint64         `binary:"packed"`
+	H map[uint32]int64 `binary:"packed"`
+	P *uint32          `binary:"packed"`
+	Q uint32
+}
+
+// Short is used to decode longer arrays.
+type Short struct {
+	Arr   [2]Point
+	Fixed [3]bool
+	Last  bool
+}
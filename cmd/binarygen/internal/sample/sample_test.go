@@ -0,0 +1,154 @@
+package sample
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vipally/binary"
+)
+
+// raw types have no generated methods, they are encoded by reflection.
+type rawMessage Message
+type rawPacked Packed
+
+func newMessage() *Message {
+	s := "pointer"
+	m := &Message{
+		ID:     0x1122334455667788,
+		Name:   "message",
+		Ok:     true,
+		Level:  -3,
+		Count:  -12345,
+		Index:  67890,
+		Small:  -8,
+		U8:     0xfe,
+		I16:    -2,
+		U16:    0xfffe,
+		F32:    3.14,
+		F64:    -2.718,
+		C64:    complex(1, 2),
+		C128:   complex(-3, 4),
+		Bytes:  []byte("bytes"),
+		Flags:  []bool{true, false, true, true, false, false, true, true, true},
+		Fixed:  [3]bool{false, true, true},
+		Arr:    [2]Point{{1, 2}, {3, 4}},
+		Points: []Point{{-1, -2}},
+		PPoint: &Point{5, 6},
+		PStr:   &s,
+		Tags:   Tags{"a", "", "c"},
+		Map:    map[string]*Point{"p": {7, 8}},
+		Wait:   -time.Second,
+		Waits:  []time.Duration{time.Minute, 1},
+		Ver:    Version{1, 2},
+		Vers:   map[string]Version{"v": {3, 4}},
+		Short:  &Short{Last: true},
+		Shorts: []Short{{Fixed: [3]bool{true}}, {Arr: [2]Point{{1, 1}}}},
+		Last:   true,
+		Point:  Point{9, 10},
+	}
+	m.Nested.A = true
+	m.Nested.B = []uint16{1, 2, 3}
+	return m
+}
+
+func newPacked() *Packed {
+	p := uint32(300)
+	return &Packed{
+		A: -1, B: 200, C: -300000, D: 4, E: 500, F: 1 << 40,
+		G: []uint64{7, 128, 1 << 63},
+		H: map[uint32]int64{1: -1},
+		P: &p,
+		Q: 0x11223344,
+	}
+}
+
+func TestGeneratedEncode(t *testing.T) {
+	cases := []struct {
+		gen, raw interface{}
+	}{
+		{newMessage(), (*rawMessage)(newMessage())},
+		{&Message{}, &rawMessage{}},
+		{newPacked(), (*rawPacked)(newPacked())},
+		{&Packed{}, &rawPacked{}},
+	}
+	for i, c := range cases {
+		b1, err := binary.Encode(c.gen, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b2, err := binary.Encode(c.raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b1, b2) {
+			t.Errorf("case %d generated Encode got %#v\nneed %#v", i, b1, b2)
+		}
+		if s1, s2 := binary.Sizeof(c.gen), binary.Sizeof(c.raw); s1 != s2 || s1 != len(b1) {
+			t.Errorf("case %d generated Size got %d, need %d", i, s1, s2)
+		}
+	}
+}
+
+func TestGeneratedDecode(t *testing.T) {
+	cases := []struct {
+		gen, raw interface{}
+	}{
+		{newMessage(), (*rawMessage)(newMessage())},
+		{newPacked(), (*rawPacked)(newPacked())},
+	}
+	for i, c := range cases {
+		b, err := binary.Encode(c.raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen := reflect.New(reflect.TypeOf(c.gen).Elem())
+		if err := binary.Decode(b, gen.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		raw := reflect.New(reflect.TypeOf(c.raw).Elem())
+		if err := binary.Decode(b, raw.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gen.Elem().Convert(raw.Elem().Type()).Interface(), raw.Elem().Interface()) {
+			t.Errorf("case %d generated Decode got %#v\nneed %#v", i, gen.Interface(), raw.Interface())
+		}
+		if !reflect.DeepEqual(gen.Interface(), c.gen) {
+			t.Errorf("case %d generated Decode got %#v\nneed %#v", i, gen.Interface(), c.gen)
+		}
+	}
+}
+
+func TestGeneratedDecodeShortArray(t *testing.T) {
+	type long struct {
+		Arr   [4]Point
+		Fixed [12]bool
+		Last  bool
+	}
+	data := long{Arr: [4]Point{{1, 2}, {3, 4}, {5, 6}, {7, 8}}, Last: true}
+	data.Fixed[1], data.Fixed[11] = true, true
+	b, err := binary.Encode(&data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s Short //Arr and Fixed are shorter than encoded
+	if err := binary.Decode(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	need := Short{Arr: [2]Point{{1, 2}, {3, 4}}, Fixed: [3]bool{false, true, false}, Last: true}
+	if s != need {
+		t.Errorf("generated Decode got %#v\nneed %#v", s, need)
+	}
+}
+
+func TestGeneratedDecodeError(t *testing.T) {
+	b, err := binary.Encode(newMessage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Message
+	if err := m.Decode(b[:len(b)/2]); err == nil {
+		t.Errorf("generated Decode: have err == nil, want non-nil")
+	}
+}
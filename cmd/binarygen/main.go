@@ -0,0 +1,72 @@
+// Binarygen generates Size/Encode/Decode methods for struct types,
+// which implement interface binary.BinarySerializer without reflection.
+// The generated methods are byte-for-byte compatible with the reflective
+// encoding of package github.com/vipally/binary.
+//
+// Usage:
+//
+//	binarygen -type T1,T2 [-output file] [dir]
+//
+// It is designed to be used with go generate, eg:
+//
+//	//go:generate binarygen -type Message
+//
+// Field tags `binary:"ignore"` and `binary:"packed"` are supported,
+// versioned structs with field tag `binary:"id=N"`, length tags
+// `binary:"len=N"`, `binary:"lenprefix=u16"` and `binary:"lenfield=Name"`,
+// byte order tags `binary:"be"` and `binary:"le"` and bit fields `binary:"bits=N"`
+// are not supported.
+// Types that contain interface, channel, function, uintptr, unsafe.Pointer
+// or pointer to pointer fields are not supported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output    = flag.String("output", "", "output file name; default <dir>/<type>_binary.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of binarygen:\n")
+	fmt.Fprintf(os.Stderr, "\tbinarygen -type T1,T2 [-output file] [dir]\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("binarygen: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	names := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	outputName := *output
+	if outputName == "" {
+		outputName = filepath.Join(dir, strings.ToLower(names[0])+"_binary.go")
+	}
+
+	src, err := Generate(dir, names, filepath.Base(outputName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(outputName, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
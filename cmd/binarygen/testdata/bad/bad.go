@@ -0,0 +1,50 @@
+package bad
+
+import "time"
+
+type Iface struct {
+	A interface{}
+}
+
+type Chan struct {
+	A chan int
+}
+
+type Uintptr struct {
+	A uintptr
+}
+
+type PPointer struct {
+	A **int
+}
+
+type Recursive struct {
+	A    int
+	Node node
+}
+
+type node struct {
+	Next *node
+}
+
+type NotStruct []int
+
+type Time struct {
+	A time.Time
+}
+
+type Versioned struct {
+	A int `binary:"id=1"`
+}
+
+type Length struct {
+	A string `binary:"len=8"`
+}
+
+type ByteOrder struct {
+	A uint16 `binary:"be"`
+}
+
+type Bits struct {
+	A uint8 `binary:"bits=4"`
+}
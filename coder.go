@@ -55,16 +55,22 @@ func (cder *coder) Skip(size int) int {
 	return -1
 }
 
-// Reset move the read/write pointer to the beginning of buffer
-// and set all reseted bytes to 0.
+// Reset move the read/write pointer to the beginning of buffer.
+// Bytes in buffer are not changed, see ResetZero.
 func (cder *coder) Reset() {
-	for i := cder.pos - 1; i >= 0; i-- { //zero encoded bytes
-		cder.buff[i] = 0
-	}
 	cder.pos = 0
 	cder.resetBoolCoder()
 }
 
+// ResetZero is the same as Reset, but also set all reseted bytes to 0.
+func (cder *coder) ResetZero() {
+	b := cder.buff[:cder.pos]
+	for i := range b { //zero encoded bytes
+		b[i] = 0
+	}
+	cder.Reset()
+}
+
 // reset the state of bool coder
 func (cder *coder) resetBoolCoder() {
 	cder.boolPos = -1
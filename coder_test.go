@@ -1095,3 +1095,219 @@ func TestBools(t *testing.T) {
 		t.Errorf("EncodeBools got %+v\nneed %+v\n", dataDecode, data)
 	}
 }
+
+func TestEncoderAutoGrow(t *testing.T) {
+	encoder := NewEncoderAutoGrow(0)
+	encoder.Uint64(0x1122334455667788, false)
+	encoder.String("0123456789abcdef")
+	for i := 0; i < 10; i++ {
+		encoder.Bool(i%2 == 0)
+	}
+	if err := encoder.Value(&full); err != nil {
+		t.Error(err)
+	}
+	size := 8 + Sizeof("0123456789abcdef") + 2 + Sizeof(&full)
+	if encoder.Len() != size {
+		t.Errorf("EncoderAutoGrow got len %d, need %d", encoder.Len(), size)
+	}
+	if s := encoder.Skip(1000); s != 1000 {
+		t.Errorf("EncoderAutoGrow Skip got %d, need %d", s, 1000)
+	}
+
+	var r fullStruct
+	decoder := NewDecoder(encoder.Buffer())
+	if x := decoder.Uint64(false); x != 0x1122334455667788 {
+		t.Errorf("EncoderAutoGrow got %#x", x)
+	}
+	if s := decoder.String(); s != "0123456789abcdef" {
+		t.Errorf("EncoderAutoGrow got %q", s)
+	}
+	for i := 0; i < 10; i++ {
+		if b := decoder.Bool(); b != (i%2 == 0) {
+			t.Errorf("EncoderAutoGrow bool %d got %v", i, b)
+		}
+	}
+	if err := decoder.Value(&r); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(r, full) {
+		t.Errorf("EncoderAutoGrow got %#v\nneed %#v\n", r, full)
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	prefix := []byte{0xa, 0xb, 0xc}
+	dst := append(make([]byte, 0, 4), prefix...)
+	b, err := AppendEncode(dst, []uint16{0x1122, 0x3344})
+	if err != nil {
+		t.Error(err)
+	}
+	check := []byte{0xa, 0xb, 0xc, 0x2, 0x22, 0x11, 0x44, 0x33}
+	if !reflect.DeepEqual(b, check) {
+		t.Errorf("AppendEncode got %#v\nneed %#v\n", b, check)
+	}
+
+	b2, err := AppendEncode(prefix, doNotSupportTypes)
+	if err == nil {
+		t.Errorf("AppendEncode: have err == nil, want non-nil")
+	}
+	if !reflect.DeepEqual(b2, prefix) {
+		t.Errorf("AppendEncode got %#v\nneed %#v\n", b2, prefix)
+	}
+
+	small := make([]byte, 1)
+	b3, err := Encode(&full, small)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(b3) != Sizeof(&full) {
+		t.Errorf("Encode got len %d, need %d", len(b3), Sizeof(&full))
+	}
+}
+
+func TestEncodePartial(t *testing.T) {
+	type unregistered struct{ A int }
+	data := struct {
+		A uint16
+		B interface{}
+	}{0x1122, unregistered{}}
+	b, err := Encode(&data, nil)
+	if err == nil {
+		t.Errorf("Encode: have err == nil, want non-nil")
+	}
+	check := []byte{0x22, 0x11} //bytes before the error
+	if !reflect.DeepEqual(b, check) {
+		t.Errorf("Encode got %#v\nneed %#v\n", b, check)
+	}
+}
+
+func TestAutoRegStruct(t *testing.T) {
+	type autoRegStruct struct {
+		A uint32 `binary:"packed"`
+		B string
+		c int
+	}
+	var data = autoRegStruct{A: 1, B: "hello", c: 2}
+	b, err := Encode(&data, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	info := _structInfoMgr.lookup(reflect.TypeOf(data))
+	if info == nil || !info.auto {
+		t.Fatalf("AutoRegStruct: have info == %v, want auto registed", info)
+	}
+	check := []byte{0x1, 0x5, 0x68, 0x65, 0x6c, 0x6c, 0x6f}
+	if !reflect.DeepEqual(b, check) {
+		t.Errorf("AutoRegStruct got %#v\nneed %#v\n", b, check)
+	}
+
+	type regStruct autoRegStruct //same fields and tags, registed before use
+	if err := RegStruct((*regStruct)(nil)); err != nil {
+		t.Error(err)
+	}
+	b2, err := Encode(regStruct(data), nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(b2, b) {
+		t.Errorf("AutoRegStruct registed got %#v\nneed %#v\n", b2, b)
+	}
+
+	if err := RegStruct((*autoRegStruct)(nil)); err != nil {
+		t.Error(err)
+	}
+	if err := RegStruct((*autoRegStruct)(nil)); err == nil { //duplicate regist
+		t.Errorf("AutoRegStruct: have err == nil, want non-nil")
+	}
+	b, err = Encode(&data, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	check = []byte{0x1, 0x5, 0x68, 0x65, 0x6c, 0x6c, 0x6f}
+	if !reflect.DeepEqual(b, check) {
+		t.Errorf("AutoRegStruct got %#v\nneed %#v\n", b, check)
+	}
+}
+
+func TestRegStructConcurrent(t *testing.T) {
+	const n = 50
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < n; i++ {
+			typ := reflect.StructOf([]reflect.StructField{
+				{Name: "A", Type: reflect.TypeOf(uint32(0))},
+				{Name: fmt.Sprintf("F%d", i), Type: reflect.TypeOf("")},
+			})
+			if err := RegStruct(reflect.New(typ).Interface()); err != nil {
+				t.Error(err)
+			}
+		}
+		done <- true
+	}()
+	for i := 0; i < n; i++ {
+		b, err := Encode(&full, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r fullStruct
+		if err := Decode(b, &r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
+
+func TestRegStructSameName(t *testing.T) {
+	//two different local types with the same name binary.sameName
+	t1 := func() interface{} {
+		type sameName struct {
+			A uint8
+			B string
+		}
+		return &sameName{A: 1, B: "a"}
+	}()
+	t2 := func() interface{} {
+		type sameName struct {
+			C string
+			D uint32
+		}
+		return &sameName{C: "c", D: 2}
+	}()
+	if reflect.TypeOf(t1).String() != reflect.TypeOf(t2).String() {
+		t.Fatalf("RegStructSameName: types have different name")
+	}
+
+	for _, v := range []interface{}{t1, t2} {
+		if err := RegStruct(v); err != nil {
+			t.Error(err)
+		}
+		err := RegStruct(v)
+		if e, ok := err.(*DuplicateTypeError); !ok || e.Type != reflect.TypeOf(v).Elem() {
+			t.Errorf("RegStructSameName: have err == %#v, want *DuplicateTypeError", err)
+		}
+	}
+
+	for _, v := range []interface{}{t1, t2} {
+		b, err := Encode(v, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		r := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		if err := Decode(b, r); err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(r, v) {
+			t.Errorf("RegStructSameName got %#v\nneed %#v\n", r, v)
+		}
+	}
+
+	found := 0
+	for _, typ := range RegisteredStructs() {
+		if typ == reflect.TypeOf(t1).Elem() || typ == reflect.TypeOf(t2).Elem() {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("RegisteredStructs: found %d types, want 2", found)
+	}
+}
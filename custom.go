@@ -0,0 +1,150 @@
+// encode/decode nested values that serialize themselves.
+
+package binary
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// customKind is the way that a type serializes itself.
+// Nested custom values are encoded as length-prefixed bytes of their own,
+// pointer to custom type is encoded with a nil flag.
+// It is checked once per type and cached, see structInfoMgr.queryCustom.
+type customKind uint8
+
+const (
+	customNone       customKind = iota
+	customSerializer            //BinarySerializer of this package
+	customMarshaler             //encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
+)
+
+var (
+	tSerializer  = reflect.TypeOf((*BinarySerializer)(nil)).Elem()
+	tMarshaler   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	tUnmarshaler = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// customOf returns the cached customKind of t.
+// Predeclared and unnamed types except struct have no methods, they are not cached.
+func customOf(t reflect.Type) customKind {
+	tt := t
+	if tt.Kind() == reflect.Ptr {
+		tt = tt.Elem()
+	}
+	if tt.PkgPath() == "" && tt.Kind() != reflect.Struct {
+		return customNone
+	}
+	return _structInfoMgr.queryCustom(t)
+}
+
+// checkCustom check if t or pointer to t serializes itself.
+// Standard library types with special codec are not custom.
+func checkCustom(t reflect.Type) customKind {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return customNone
+	}
+	if stdTypeOf(t) != nil {
+		return customNone
+	}
+	switch p := reflect.PtrTo(t); {
+	case p.Implements(tSerializer):
+		return customSerializer
+	case p.Implements(tMarshaler) && p.Implements(tUnmarshaler):
+		return customMarshaler
+	}
+	return customNone
+}
+
+// bitsOfCustom returns number of bits to encode custom value v, or -1 if it fails.
+func bitsOfCustom(v reflect.Value, kind customKind) int {
+	bits := 0
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 1
+		}
+		bits, v = 1, v.Elem()
+	}
+	size := 0
+	if kind == customSerializer {
+		size = addrOf(v).(BinarySizer).Size()
+	} else {
+		b, err := addrOf(v).(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return -1
+		}
+		size = len(b)
+	}
+	return bits + sizeofString(size)*8
+}
+
+// custom encode custom value v as length-prefixed bytes.
+func (encoder *Encoder) custom(v reflect.Value, kind customKind) {
+	if v.Kind() == reflect.Ptr {
+		encoder.Bool(!v.IsNil())
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if kind == customSerializer {
+		p := addrOf(v).(BinarySerializer)
+		size := p.Size()
+		encoder.Uvarint(uint64(size))
+		buff := encoder.reserve(size)
+		r, err := p.Encode(buff)
+		if err != nil {
+			panic(err)
+		}
+		if len(r) != size {
+			panic(fmt.Errorf("binary.Encoder.Value: %s encoded %d bytes, but Size is %d", v.Type().String(), len(r), size))
+		}
+		copy(buff, r) //r may not share memory with buff
+		return
+	}
+	b, err := addrOf(v).(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	encoder.Uvarint(uint64(len(b)))
+	copy(encoder.reserve(len(b)), b)
+}
+
+// custom decode length-prefixed bytes to settable custom value v.
+func (decoder *Decoder) custom(v reflect.Value, kind customKind) {
+	if v.Kind() == reflect.Ptr {
+		if !decoder.Bool() {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	b := decoder.bytes()
+	var err error
+	if kind == customSerializer {
+		err = v.Addr().Interface().(BinaryDecoder).Decode(b)
+	} else {
+		err = v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// skipCustom skip a custom value of type t, it returns number of bytes skipped.
+func (decoder *Decoder) skipCustom(t reflect.Type) int {
+	start := decoder.Consumed()
+	if t.Kind() == reflect.Ptr && !decoder.Bool() {
+		return 1
+	}
+	decoder.Skip(decoder.length("MaxStringLen", decoder.limits.MaxStringLen, 8))
+	return int(decoder.Consumed() - start)
+}
@@ -0,0 +1,108 @@
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// point serializes itself as 2 bytes.
+type point struct {
+	X, Y int8
+}
+
+func (p *point) Size() int { return 2 }
+
+func (p *point) Encode(buffer []byte) ([]byte, error) {
+	return append(buffer[:0], byte(p.X), byte(p.Y)), nil
+}
+
+func (p *point) Decode(buffer []byte) error {
+	if len(buffer) != 2 {
+		return errors.New("point: invalid size")
+	}
+	p.X, p.Y = int8(buffer[0]), int8(buffer[1])
+	return nil
+}
+
+// version serializes itself by encoding.BinaryMarshaler.
+type version struct {
+	major, minor uint8 //unexported fields are not encoded by reflection
+}
+
+func (v version) MarshalBinary() ([]byte, error) {
+	return []byte{v.major, v.minor}, nil
+}
+
+func (v *version) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return errors.New("version: invalid size")
+	}
+	v.major, v.minor = b[0], b[1]
+	return nil
+}
+
+type customStruct struct {
+	A      uint8
+	Point  point
+	PPoint *point
+	Nil    *point
+	Ver    version
+	Points []point
+	Vers   map[string]version
+	Any    interface{}
+}
+
+func TestCustomNested(t *testing.T) {
+	RegisterName("binary.point", point{})
+	data := &customStruct{
+		A:      1,
+		Point:  point{-1, 2},
+		PPoint: &point{3, 4},
+		Ver:    version{1, 2},
+		Points: []point{{5, 6}, {7, 8}},
+		Vers:   map[string]version{"v": {3, 4}},
+		Any:    point{9, 10},
+	}
+	b, err := Encode(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := Sizeof(data); size != len(b) {
+		t.Errorf("Sizeof got %d, need %d", size, len(b))
+	}
+	//A, len+Point, nil flags, len+PPoint, len+Ver
+	if need := []byte{1, 2, 0xff, 2, 0x1, 2, 3, 4, 2, 1, 2}; !reflect.DeepEqual(b[:len(need)], need) {
+		t.Errorf("got % x\nneed % x", b[:len(need)], need)
+	}
+	var r customStruct
+	if err := Decode(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&r, data) {
+		t.Errorf("got %#v\nneed %#v", r, data)
+	}
+
+	//canonical encoding is the same for a single entry map
+	if c, err := EncodeCanonical(data, nil); err != nil || !reflect.DeepEqual(c, b) {
+		t.Errorf("EncodeCanonical got % x %v", c, err)
+	}
+
+	//skip elements that the array can not hold
+	var a [1]version
+	if b, err = Encode([]version{{1, 2}, {3, 4}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewDecoder(b)
+	if err := decoder.Value(&a); err != nil || a[0] != (version{1, 2}) || decoder.Len() != len(b) {
+		t.Errorf("got %v %v, decoded %d of %d bytes", a, err, decoder.Len(), len(b))
+	}
+
+	//errors of Decode are reported with path
+	b, _ = Encode(data, nil)
+	b[1] = 1 //length of Point
+	var e *DecodeError
+	if err := Decode(b, &r); !errors.As(err, &e) || e.Path != "customStruct.Point" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"unsafe"
 )
 
 // NewDecoder make a new Decoder object with buffer.
@@ -19,11 +20,37 @@ func NewDecoderEndian(buffer []byte, endian Endian) *Decoder {
 	return p
 }
 
+// NewStreamDecoder make a new Decoder object that reads from r.
+// Bytes are read from r into an internal buffer as needed,
+// so it may read more bytes from r than the decoded values need.
+// It can decode a sequence of values from r, Value returns io.EOF
+// if r reaches EOF before a new value.
+func NewStreamDecoder(r io.Reader) *Decoder {
+	return NewStreamDecoderEndian(r, DefaultEndian)
+}
+
+// NewStreamDecoderEndian make a new Decoder object that reads from r with endian.
+func NewStreamDecoderEndian(r io.Reader, endian Endian) *Decoder {
+	p := NewDecoderEndian(make([]byte, 0, defaultStreamBufferSize), endian)
+	p.reader = r
+	p.readAhead = true
+	return p
+}
+
 // Decoder is used to decode byte array to go data.
 type Decoder struct {
 	coder
-	reader    io.Reader //for decode from reader only
-	boolValue byte      //last bool value byte
+	reader        io.Reader //for decode from reader only
+	readAhead     bool      //read as many bytes as buffer can hold from reader
+	offset        int64     //number of bytes consumed before buff[0]
+	start         int64     //consumed bytes when current Value begins
+	limits        Limits    //limits for untrusted input
+	depth         int       //nesting depth of current value
+	strict        bool      //reject map entries that are not in canonical order
+	stdCompatible bool      //decode as encoding/binary, see stdcompat.go
+	zeroCopy      bool      //decoded []byte and string refer to buff, see zerocopy.go
+	boolValue     byte      //last bool value byte
+	pooled        bool      //acquired by AcquireDecoder and not released yet
 }
 
 // Skip ignore the next size of bytes for encoding/decoding.
@@ -36,27 +63,97 @@ func (decoder *Decoder) Skip(size int) int {
 	return size
 }
 
+// Consumed returns number of bytes that has been decoded.
+// For stream Decoder, it counts all bytes consumed from reader,
+// except the bytes that are buffered but not decoded yet.
+func (decoder *Decoder) Consumed() int64 {
+	return decoder.offset + int64(decoder.pos)
+}
+
 // reserve returns next size bytes for encoding/decoding.
 func (decoder *Decoder) reserve(size int) []byte {
-	if decoder.reader != nil { //decode from reader
-		if size > len(decoder.buff) {
-			decoder.buff = make([]byte, size)
-		}
-		buff := decoder.buff[:size]
-		if n, _ := decoder.reader.Read(buff); n < size {
-			panic(io.ErrUnexpectedEOF)
+	decoder.checkBytes(size)
+	if decoder.pos+size > len(decoder.buff) {
+		if decoder.reader == nil {
+			panic(decoder.truncated(size))
+		}
+		if err := decoder.fill(size); err != nil { //decode from reader
+			if err == io.ErrUnexpectedEOF {
+				err = decoder.truncated(size)
+			}
+			panic(err)
 		}
-		return buff
 	}
 
 	return decoder.coder.reserve(size) //decode from bytes buffer
 }
 
+// truncated returns error for lack of bytes to decode size bytes.
+func (decoder *Decoder) truncated(size int) error {
+	return &TruncatedError{Offset: decoder.Consumed(), Need: size, Have: len(decoder.buff) - decoder.pos}
+}
+
+// fill reads from reader until there is at least size bytes after pos.
+// Decoded bytes are dropped from buffer.
+// Buffer grows step by step as bytes arrive, so a hostile size can not
+// allocate much more memory than the real input.
+// It returns io.EOF only if reader is at EOF when a Value begins,
+// and returns io.ErrUnexpectedEOF if EOF happens in the middle of a value.
+func (decoder *Decoder) fill(size int) error {
+	for len(decoder.buff)-decoder.pos < size {
+		unread := len(decoder.buff) - decoder.pos
+		buff := decoder.buff
+		if size > cap(buff) {
+			n := 2 * cap(buff)
+			if n < defaultStreamBufferSize {
+				n = defaultStreamBufferSize
+			}
+			if n > size {
+				n = size
+			}
+			buff = make([]byte, n)
+		}
+		copy(buff[:unread], decoder.buff[decoder.pos:])
+		decoder.offset += int64(decoder.pos)
+		decoder.pos = 0
+
+		want := size
+		if want > cap(buff) {
+			want = cap(buff)
+		}
+		var n int
+		var err error
+		if decoder.readAhead {
+			n, err = io.ReadAtLeast(decoder.reader, buff[unread:cap(buff)], want-unread)
+		} else { //never read bytes that are not required
+			n, err = io.ReadFull(decoder.reader, buff[unread:want])
+		}
+		decoder.buff = buff[:unread+n]
+
+		switch {
+		case err == nil:
+		case err == io.EOF && unread == 0 && decoder.readAhead && decoder.Consumed() == decoder.start:
+			return err
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return io.ErrUnexpectedEOF
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
 // Init initialize Encoder with buffer and endian.
 func (decoder *Decoder) Init(buffer []byte, endian Endian) {
 	decoder.buff = buffer
 	decoder.pos = 0
 	decoder.endian = endian
+	decoder.reader = nil
+	decoder.readAhead = false
+	decoder.offset = 0
+	decoder.start = 0
+	decoder.limits = DefaultLimits
+	decoder.depth = 0
 }
 
 // Bool decode a bool value from Decoder buffer.
@@ -189,12 +286,18 @@ func (decoder *Decoder) Complex128() complex128 {
 }
 
 // String decode a string value from Decoder buffer.
+// The string shares memory with the buffer in zero-copy mode, see SetZeroCopy.
 // It will panic if buffer is not enough.
 func (decoder *Decoder) String() string {
-	s, _ := decoder.Uvarint()
-	size := int(s)
+	size := decoder.length("MaxStringLen", decoder.limits.MaxStringLen, 8)
 	b := decoder.reserve(size)
-	return string(b)
+	return decoder.makeString(b)
+}
+
+// bytes decode length-prefixed bytes that refer to decoder buffer.
+func (decoder *Decoder) bytes() []byte {
+	size := decoder.length("MaxStringLen", decoder.limits.MaxStringLen, 8)
+	return decoder.reserve(size)
 }
 
 // Int decode an int value from Decoder buffer.
@@ -252,11 +355,17 @@ func (decoder *Decoder) Value(x interface{}) (err error) {
 		if info := recover(); info != nil {
 			err = info.(error)
 			assert(err != nil, info)
+			err = decoder.errorOf(err, x)
 		}
 	}()
 
 	decoder.resetBoolCoder() //reset bool reader
+	decoder.start = decoder.Consumed()
+	decoder.depth = 0
 
+	if decoder.stdCompatible {
+		return decoder.stdValue(x)
+	}
 	if decoder.fastValue(x) { //fast value path
 		return nil
 	}
@@ -264,21 +373,14 @@ func (decoder *Decoder) Value(x interface{}) (err error) {
 	v := reflect.ValueOf(x)
 
 	if p, ok := x.(BinaryDecoder); ok {
-		size := 0
-		if sizer, _ok := x.(BinarySizer); _ok { //interface verification
-			size = sizer.Size()
-		} else {
+		sizer, _ok := x.(BinarySizer)
+		if !_ok { //interface verification
 			panic(fmt.Errorf("expect but not BinarySizer: %s", v.Type().String()))
 		}
 		if _, _ok := x.(BinaryEncoder); !_ok { //interface verification
 			panic(fmt.Errorf("unexpect but not BinaryEncoder: %s", v.Type().String()))
 		}
-		err := p.Decode(decoder.buff[decoder.pos:])
-		if err != nil {
-			return err
-		}
-		decoder.reserve(size)
-		return nil
+		return decoder.serializer(p, sizer)
 	}
 
 	if _, _ok := x.(BinarySizer); _ok { //interface verification
@@ -289,12 +391,65 @@ func (decoder *Decoder) Value(x interface{}) (err error) {
 	}
 
 	if v.Kind() == reflect.Ptr { //only support decode for pointer interface
-		return decoder.value(v, true, false)
+		if err := decoder.value(v, true, false); err != nil {
+			return decoder.errorOf(err, x)
+		}
+		return nil
 	}
 
 	return fmt.Errorf("binary.Decoder.Value: non-pointer type %s", v.Type().String())
 }
 
+// errorOf returns *DecodeError of err with path of the failed value.
+// io.EOF at the beginning of a value and *UnsupportedTypeError of
+// the top level value are returned directly.
+func (decoder *Decoder) errorOf(err error, x interface{}) error {
+	p, ok := err.(*pathError)
+	if !ok {
+		if _, ok := err.(*UnsupportedTypeError); ok || err == io.EOF {
+			return err
+		}
+		p = &pathError{t: rootType(x), offset: decoder.start, err: err}
+	}
+	return &DecodeError{Offset: p.offset, Type: p.t, Path: p.path(rootType(x)), Err: p.err}
+}
+
+// serializer decode a BinaryDecoder value.
+// Size of p is checked after Decode, because it may depend on the decoded data.
+// Decoder from reader reads more bytes and decode again if p.Decode fails
+// or p needs more bytes than buffered.
+func (decoder *Decoder) serializer(p BinaryDecoder, sizer BinarySizer) error {
+	want := sizer.Size()
+	for {
+		var eof error
+		if decoder.reader != nil && want > len(decoder.buff)-decoder.pos {
+			if eof = decoder.fill(want); eof != nil && eof != io.ErrUnexpectedEOF {
+				return eof
+			}
+		}
+		avail := len(decoder.buff) - decoder.pos
+		err := p.Decode(decoder.buff[decoder.pos:])
+		size := sizer.Size()
+		if err == nil && size <= avail {
+			decoder.reserve(size)
+			return nil
+		}
+
+		//read more bytes from reader and try again
+		if decoder.reader == nil || eof != nil {
+			if err != nil {
+				return err
+			}
+			decoder.reserve(size) //not enough bytes, it will panic
+		}
+		if want = size; want <= avail {
+			if want = avail + 1; decoder.readAhead {
+				want = 2*avail + 1
+			}
+		}
+	}
+}
+
 func (decoder *Decoder) value(v reflect.Value, topLevel bool, packed bool) error {
 	// check Packer interface for every value is perfect
 	// but decoder is too costly
@@ -325,6 +480,20 @@ func (decoder *Decoder) value(v reflect.Value, topLevel bool, packed bool) error
 	//		}
 	//	}
 
+	if t := v.Type(); topLevel && t.Kind() == reflect.Ptr && !v.IsNil() { //compiled plan, see plan.go
+		if p := planOf(t.Elem(), packed); p != nil {
+			decoder.plan(p, v.UnsafePointer())
+			return nil
+		}
+	} else if p := planOf(t, packed); p != nil && v.CanSet() {
+		decoder.plan(p, unsafe.Pointer(v.UnsafeAddr()))
+		return nil
+	}
+	if std := stdTypeOf(v.Type()); std != nil { //standard library types
+		decoder.std(std, v)
+		return nil
+	}
+
 	switch k := v.Kind(); k {
 	case reflect.Int:
 		v.SetInt(int64(decoder.Int()))
@@ -368,51 +537,23 @@ func (decoder *Decoder) value(v reflect.Value, topLevel bool, packed bool) error
 
 	case reflect.Slice, reflect.Array:
 		if !validUserType(v.Type().Elem()) { //verify array element is valid
-			return fmt.Errorf("binary.Decoder.Value: unsupported type %s", v.Type().String())
-		}
-		if decoder.boolArray(v) < 0 { //deal with bool array first
-			s, _ := decoder.Uvarint()
-			size := int(s)
-			if size > 0 && k == reflect.Slice { //make a new slice
-				ns := reflect.MakeSlice(v.Type(), size, size)
-				v.Set(ns)
-			}
-
-			l := v.Len()
-			for i := 0; i < size; i++ {
-				if i < l {
-					assert(decoder.value(v.Index(i), false, packed) == nil, "")
-				} else {
-					skiped := decoder.skipByType(v.Type().Elem(), packed)
-					assert(skiped >= 0, v.Type().Elem().String()) //I'm sure here cannot find unsupported type
-				}
-			}
+			return &UnsupportedTypeError{Type: v.Type(), op: "binary.Decoder.Value"}
 		}
+		decoder.slice(v, packed)
 	case reflect.Map:
-		t := v.Type()
-		kt := t.Key()
-		vt := t.Elem()
-		if !validUserType(kt) ||
-			!validUserType(vt) { //verify map key and value type are both valid
-			return fmt.Errorf("binary.Decoder.Value: unsupported type %s", v.Type().String())
-		}
-
-		if v.IsNil() {
-			newmap := reflect.MakeMap(v.Type())
-			v.Set(newmap)
-		}
-
-		s, _ := decoder.Uvarint()
-		size := int(s)
-		for i := 0; i < size; i++ {
-			key := reflect.New(kt).Elem()
-			value := reflect.New(vt).Elem()
-			assert(decoder.value(key, false, packed) == nil, "")
-			assert(decoder.value(value, false, packed) == nil, "")
-			v.SetMapIndex(key, value)
+		if !validUserType(v.Type().Key()) ||
+			!validUserType(v.Type().Elem()) { //verify map key and value type are both valid
+			return &UnsupportedTypeError{Type: v.Type(), op: "binary.Decoder.Value"}
 		}
+		decoder.mapValue(v, packed)
 	case reflect.Struct:
-		return queryStruct(v.Type()).decode(decoder, v)
+		decoder.enter()
+		err := queryStruct(v.Type()).decode(decoder, v)
+		decoder.leave()
+		return err
+
+	case reflect.Interface:
+		decoder.iface(v)
 
 	default:
 		if newPtr(v, decoder, topLevel) {
@@ -420,12 +561,174 @@ func (decoder *Decoder) value(v reflect.Value, topLevel bool, packed bool) error
 				return decoder.value(v.Elem(), false, packed)
 			}
 		} else {
-			return fmt.Errorf("binary.Decoder.Value: unsupported type %s", v.Type().String())
+			return &UnsupportedTypeError{Type: v.Type(), op: "binary.Decoder.Value"}
 		}
 	}
 	return nil
 }
 
+// slice decode slice or array v.
+// Path of the failed element is added to error while unwinding.
+func (decoder *Decoder) slice(v reflect.Value, packed bool) {
+	i, offset := -1, decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			t := v.Type()
+			if i >= 0 {
+				t = t.Elem()
+			}
+			tracePath(e, indexPath(i), t, offset)
+		}
+	}()
+
+	decoder.enter()
+	if isBytesType(v.Type()) {
+		decoder.byteSlice(v)
+	} else if decoder.boolArray(v) < 0 { //deal with bool array first
+		bits := minBitsOf(v.Type().Elem(), packed)
+		size := decoder.SliceLen(bits)
+		if size > 0 && v.Kind() == reflect.Slice { //make a new slice
+			ns := reflect.MakeSlice(v.Type(), size, size)
+			v.Set(ns)
+		}
+		if bits == 0 { //nothing to decode for empty elements
+			size = 0
+		}
+
+		kind := customOf(v.Type().Elem())
+		l := v.Len()
+		for i = 0; i < size; i++ {
+			offset = decoder.Consumed()
+			if i < l {
+				decoder.elem(v.Index(i), kind, packed)
+			} else {
+				skiped := decoder.skipByType(v.Type().Elem(), packed)
+				assert(skiped >= 0, v.Type().Elem().String()) //I'm sure here cannot find unsupported type
+			}
+		}
+	}
+	decoder.leave()
+}
+
+// mapValue decode map v.
+// Path of the failed entry is added to error while unwinding.
+func (decoder *Decoder) mapValue(v reflect.Value, packed bool) {
+	t := v.Type()
+	kt := t.Key()
+	vt := t.Elem()
+	i, offset := -1, decoder.Consumed()
+	var key reflect.Value //key of entry i, invalid before it is decoded
+	defer func() {
+		if e := recover(); e != nil {
+			et := t
+			switch {
+			case key.IsValid():
+				et = vt
+			case i >= 0:
+				et = kt
+			}
+			seg := ""
+			if i >= 0 {
+				seg = keyPath(i, key)
+			}
+			tracePath(e, seg, et, offset)
+		}
+	}()
+
+	decoder.enter()
+	if v.IsNil() {
+		newmap := reflect.MakeMap(t)
+		v.Set(newmap)
+	}
+
+	bits := minBitsOf(kt, packed) + minBitsOf(vt, packed)
+	size := decoder.MapLen(bits)
+	if bits == 0 && size > 1 { //all the empty keys are equal
+		size = 1
+	}
+	keyKind, valueKind := customOf(kt), customOf(vt)
+	var prev, prevValue reflect.Value
+	for i = 0; i < size; i++ {
+		offset, key = decoder.Consumed(), reflect.Value{}
+		k := reflect.New(kt).Elem()
+		value := reflect.New(vt).Elem()
+		decoder.elem(k, keyKind, packed)
+		equal := decoder.strict && i > 0 && decoder.checkKey(v, prev, k, packed)
+		offset, key = decoder.Consumed(), k
+		decoder.elem(value, valueKind, packed)
+		if equal {
+			decoder.checkValue(k, prevValue, value, packed)
+		}
+		prev, prevValue = k, value
+		v.SetMapIndex(k, value)
+	}
+	decoder.leave()
+}
+
+// iface decode interface value v as type tag followed by it's concrete value.
+// It will panic if the type tag is not registed or the concrete type
+// does not implement the interface.
+// Path of the concrete type is added to error while unwinding.
+func (decoder *Decoder) iface(v reflect.Value) {
+	var t reflect.Type
+	offset := decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			if t == nil { //type tag fails
+				tracePath(e, "", v.Type(), offset)
+			} else {
+				tracePath(e, ".("+t.String()+")", t, offset)
+			}
+		}
+	}()
+
+	decoder.enter()
+	if t = decoder.ifaceType(); t == nil {
+		v.Set(reflect.Zero(v.Type()))
+		decoder.leave()
+		return
+	}
+	offset = decoder.Consumed()
+	if !t.AssignableTo(v.Type()) {
+		panic(fmt.Errorf("binary.Decoder.Value: type %s is not assignable to %s", t.String(), v.Type().String()))
+	}
+	e := reflect.New(t).Elem()
+	decoder.elem(e, customOf(t), false)
+	v.Set(e)
+	decoder.leave()
+}
+
+// elem decode nested value v that may be custom.
+func (decoder *Decoder) elem(v reflect.Value, kind customKind, packed bool) {
+	if kind != customNone {
+		decoder.custom(v, kind)
+		return
+	}
+	if err := decoder.value(v, false, packed); err != nil {
+		panic(err)
+	}
+}
+
+// ifaceType decode type tag of interface value and returns the registed type.
+// It returns nil for nil interface.
+func (decoder *Decoder) ifaceType() reflect.Type {
+	var t reflect.Type
+	switch tag, _ := decoder.Uvarint(); tag {
+	case ifaceNil:
+		return nil
+	case ifaceName:
+		name := decoder.String()
+		if t = _ifaceTypeMgr.queryName(name); t == nil {
+			panic(fmt.Errorf("binary.Decoder.Value: unregistered interface type name %q", name))
+		}
+	default:
+		if t = _ifaceTypeMgr.queryID(tag - ifaceIDBase); t == nil {
+			panic(fmt.Errorf("binary.Decoder.Value: unregistered interface type id %d", tag-ifaceIDBase))
+		}
+	}
+	return t
+}
+
 func (decoder *Decoder) fastValue(x interface{}) bool {
 	switch d := x.(type) {
 	case *int:
@@ -468,8 +771,7 @@ func (decoder *Decoder) fastValue(x interface{}) bool {
 		*d = decoder.String()
 
 	case *[]bool:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(1)
 		*d = make([]bool, l)
 		var b []byte
 		for i := 0; i < l; i++ {
@@ -483,107 +785,67 @@ func (decoder *Decoder) fastValue(x interface{}) bool {
 		}
 
 	case *[]int:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(8)
 		*d = make([]int, l)
 		for i := 0; i < l; i++ {
 			(*d)[i] = decoder.Int()
 		}
 	case *[]uint:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(8)
 		*d = make([]uint, l)
 		for i := 0; i < l; i++ {
 			(*d)[i] = decoder.Uint()
 		}
 
 	case *[]int8:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(8)
 		*d = make([]int8, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Int8()
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 1), l*1, 1)
 	case *[]uint8:
-		s, _ := decoder.Uvarint()
-		l := int(s)
-		*d = make([]uint8, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Uint8()
-		}
+		l := decoder.SliceLen(8)
+		*d = decoder.makeBytes(decoder.reserve(l))
 	case *[]int16:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(16)
 		*d = make([]int16, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Int16(false)
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 2), l*2, 2)
 	case *[]uint16:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(16)
 		*d = make([]uint16, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Uint16(false)
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 2), l*2, 2)
 	case *[]int32:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(32)
 		*d = make([]int32, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Int32(false)
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 4), l*4, 4)
 	case *[]uint32:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(32)
 		*d = make([]uint32, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Uint32(false)
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 4), l*4, 4)
 	case *[]int64:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(64)
 		*d = make([]int64, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Int64(false)
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 8), l*8, 8)
 	case *[]uint64:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(64)
 		*d = make([]uint64, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Uint64(false)
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 8), l*8, 8)
 	case *[]float32:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(32)
 		*d = make([]float32, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Float32()
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 4), l*4, 4)
 	case *[]float64:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(64)
 		*d = make([]float64, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Float64()
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 8), l*8, 8)
 	case *[]complex64:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(64)
 		*d = make([]complex64, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Complex64()
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 8), l*8, 4)
 	case *[]complex128:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(128)
 		*d = make([]complex128, l)
-		for i := 0; i < l; i++ {
-			(*d)[i] = decoder.Complex128()
-		}
+		decoder.readNumbers(numbersOf(unsafe.Pointer(d), 16), l*16, 8)
 	case *[]string:
-		s, _ := decoder.Uvarint()
-		l := int(s)
+		l := decoder.SliceLen(8)
 		*d = make([]string, l)
 		for i := 0; i < l; i++ {
 			(*d)[i] = decoder.String()
@@ -595,6 +857,9 @@ func (decoder *Decoder) fastValue(x interface{}) bool {
 }
 
 func (decoder *Decoder) skipByType(t reflect.Type, packed bool) int {
+	if p := planOf(t, packed); p != nil { //compiled plan, see plan.go
+		return decoder.skipPlan(p)
+	}
 	if s := fixedTypeSize(t); s > 0 {
 		if packedType := packedIntsType(t); packedType > 0 && packed {
 			switch packedType {
@@ -610,6 +875,14 @@ func (decoder *Decoder) skipByType(t reflect.Type, packed bool) int {
 			return s
 		}
 	}
+	if customOf(t) != customNone {
+		return decoder.skipCustom(t)
+	}
+	if std := stdTypeOf(t); std != nil { //decode it to know the size
+		start := decoder.Consumed()
+		decoder.std(std, reflect.New(t).Elem())
+		return int(decoder.Consumed() - start)
+	}
 	switch t.Kind() {
 	case reflect.Ptr:
 		if isNotNil := decoder.Bool(); isNotNil {
@@ -626,48 +899,71 @@ func (decoder *Decoder) skipByType(t reflect.Type, packed bool) int {
 		_, n := decoder.Uvarint()
 		return n
 	case reflect.String:
-		s, n := decoder.Uvarint()
-		size := int(s) //string length and data
+		start := decoder.Consumed()
+		size := decoder.length("MaxStringLen", decoder.limits.MaxStringLen, 8) //string length and data
 		decoder.Skip(size)
-		return size + n
+		return int(decoder.Consumed() - start)
 	case reflect.Slice, reflect.Array:
-		s, sLen := decoder.Uvarint()
-		cnt := int(s)
+		decoder.enter()
+		defer decoder.leave()
 		elemtype := t.Elem()
-		if s := fixedTypeSize(elemtype); s > 0 {
-			size := cnt * s
-			decoder.Skip(size)
-			return size
-		}
-
 		if elemtype.Kind() == reflect.Bool { //compressed bool array
+			cnt := decoder.SliceLen(1)
 			totalSize := sizeofBoolArray(cnt)
 			size := totalSize - SizeofUvarint(uint64(cnt)) //cnt has been read
 			decoder.Skip(size)
 			return totalSize
 		}
 
-		sum := sLen //array size
-		for i, n := 0, cnt; i < n; i++ {
-			s := decoder.skipByType(elemtype, packed)
-			assert(s >= 0, "skip fail: "+elemtype.String()) //I'm sure here cannot find unsupported type
-			sum += s
+		start := decoder.Consumed()
+		bits := minBitsOf(elemtype, packed)
+		cnt := decoder.SliceLen(bits)
+		if s := fixedTypeSize(elemtype); s > 0 && !(packed && packedIntsType(elemtype) > 0) {
+			decoder.Skip(cnt * s)
+		} else if bits > 0 { //nothing to skip for empty elements
+			for i, n := 0, cnt; i < n; i++ {
+				s := decoder.skipByType(elemtype, packed)
+				assert(s >= 0, "skip fail: "+elemtype.String()) //I'm sure here cannot find unsupported type
+			}
 		}
-		return sum
+		return int(decoder.Consumed() - start)
 	case reflect.Map:
-		s, sLen := decoder.Uvarint()
-		cnt := int(s)
+		if decoder.strict { //check keys by decoding it
+			start := decoder.Consumed()
+			if err := decoder.value(reflect.New(t).Elem(), false, packed); err != nil {
+				panic(err)
+			}
+			return int(decoder.Consumed() - start)
+		}
+		decoder.enter()
+		defer decoder.leave()
 		kt := t.Key()
 		vt := t.Elem()
-		sum := sLen //array size
+		start := decoder.Consumed()
+		bits := minBitsOf(kt, packed) + minBitsOf(vt, packed)
+		cnt := decoder.MapLen(bits)
+		if bits == 0 && cnt > 1 { //all the empty keys are equal
+			cnt = 1
+		}
 		for i, n := 0, cnt; i < n; i++ {
-			sum += decoder.skipByType(kt, packed)
-			sum += decoder.skipByType(vt, packed)
+			decoder.skipByType(kt, packed)
+			decoder.skipByType(vt, packed)
 		}
-		return sum
+		return int(decoder.Consumed() - start)
 
 	case reflect.Struct:
+		decoder.enter()
+		defer decoder.leave()
 		return queryStruct(t).decodeSkipByType(decoder, t, packed)
+
+	case reflect.Interface:
+		decoder.enter()
+		defer decoder.leave()
+		start := decoder.Consumed()
+		if et := decoder.ifaceType(); et != nil {
+			decoder.skipByType(et, false)
+		}
+		return int(decoder.Consumed() - start)
 	}
 	return -1
 }
@@ -676,20 +972,21 @@ func (decoder *Decoder) skipByType(t reflect.Type, packed bool) int {
 func (decoder *Decoder) boolArray(v reflect.Value) int {
 	if k := v.Kind(); k == reflect.Slice || k == reflect.Array {
 		if v.Type().Elem().Kind() == reflect.Bool {
-			_l, _ := decoder.Uvarint()
-			l := int(_l)
+			l := decoder.SliceLen(1)
 			if k == reflect.Slice && l > 0 { //make a new slice
 				v.Set(reflect.MakeSlice(v.Type(), l, l))
 			}
 			var b []byte
-			for i := 0; i < l; i++ {
+			for i, n := 0, v.Len(); i < l; i++ {
 				_, bit := i/8, i%8
 				mask := byte(1 << uint(bit))
 				if bit == 0 {
 					b = decoder.reserve(1)
 				}
-				x := ((b[0] & mask) != 0)
-				v.Index(i).SetBool(x)
+				if i < n { //skip bits out of array
+					x := ((b[0] & mask) != 0)
+					v.Index(i).SetBool(x)
+				}
 			}
 			return sizeofBoolArray(l)
 		}
@@ -0,0 +1,371 @@
+// self-describing encoding with embedded Schema.
+
+package binary
+
+import (
+	"fmt"
+	mathbig "math/big"
+	"reflect"
+	"time"
+)
+
+// EncodeDescribed marshal go data to self-describing byte array,
+// which is the Schema of data followed by the encoded data.
+// It can be decoded by DecodeDescribed without the original type,
+// or by Decode after the Schema is skipped by SkipSchema.
+//
+// The encoded data is the same as Encode, except that a top level
+// BinarySerializer or encoding.BinaryMarshaler is encoded as length-prefixed bytes.
+// Interface values are described by the types that are registed when encoding.
+func EncodeDescribed(data interface{}, buffer []byte) ([]byte, error) {
+	t := rootType(data)
+	s, err := SchemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+	encoder := NewEncoderBuffer(buffer[:cap(buffer)])
+	encoder.autoGrow = true
+	encoder.schema(s)
+	if err := encoder.described(data, t); err != nil {
+		return nil, err
+	}
+	return encoder.Buffer(), nil
+}
+
+// described encode data of type t as a nested value, so that it matches SchemaOf(t).
+func (encoder *Encoder) described(data interface{}, t reflect.Type) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = encoder.errorOf(e.(error), data)
+		}
+	}()
+	encoder.resetBoolCoder()
+	encoder.start = encoder.offset()
+	encoder.elem(reflect.Indirect(reflect.ValueOf(data)), customOf(t), false)
+	return nil
+}
+
+// DecodeDescribed unmarshal self-describing byte array encoded by EncodeDescribed.
+// It returns the decoded value and it's Schema.
+// Values are decoded as:
+//
+//	bool, numbers and string: the go type of kind, time.Duration for time.Duration
+//	time.Time, math/big.Int/Float/Rat, net/netip.Addr/Prefix, net/url.URL: the same type
+//	other CodecBytes: []byte
+//	struct: map[string]interface{} of encoded fields
+//	[]uint8 and [N]uint8: []byte
+//	other slice and array: []interface{}
+//	map with string key: map[string]interface{}
+//	map with bool or number key: map[interface{}]interface{}
+//	other map: []interface{} of [2]interface{}{key, value}
+//	pointer and interface: nil or the value they point to or hold
+func DecodeDescribed(buffer []byte) (x interface{}, s *Schema, err error) {
+	var decoder Decoder
+	decoder.Init(buffer, DefaultEndian)
+	defer func() {
+		if e := recover(); e != nil {
+			err = decoder.errorOf(e.(error), nil)
+			if p, ok := e.(*pathError); ok && s != nil {
+				err.(*DecodeError).Path = p.pathFrom(s.Name)
+			}
+			x = nil
+		}
+	}()
+	s = decoder.schema()
+	decoder.start = decoder.Consumed()
+	x = decoder.described(s)
+	return x, s, nil
+}
+
+// SkipSchema returns the encoded data after Schema of self-describing buffer,
+// which can be decoded by Decode to the original type.
+func SkipSchema(buffer []byte) ([]byte, error) {
+	_, n, err := DecodeSchema(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer[n:], nil
+}
+
+// AppendSchema appends encoded s to buffer, as the header of self-describing data.
+// Schemas of types can be saved by it and then loaded by DecodeSchema,
+// to decode or Dump data that is not self-describing.
+func AppendSchema(buffer []byte, s *Schema) []byte {
+	encoder := NewEncoderBuffer(buffer[len(buffer):cap(buffer)])
+	encoder.autoGrow = true
+	encoder.schema(s)
+	return append(buffer, encoder.Buffer()...)
+}
+
+// DecodeSchema decode Schema at the beginning of buffer, which is encoded by
+// AppendSchema or EncodeDescribed. It returns the Schema and number of bytes decoded.
+func DecodeSchema(buffer []byte) (s *Schema, n int, err error) {
+	var decoder Decoder
+	decoder.Init(buffer, DefaultEndian)
+	defer func() {
+		if e := recover(); e != nil {
+			s, n, err = nil, 0, decoder.errorOf(e.(error), nil)
+		}
+	}()
+	s = decoder.schema()
+	return s, decoder.pos, nil
+}
+
+// stdTypeNames is the standard library types with CodecBytes by Schema.Name.
+var stdTypeNames = func() map[string]reflect.Type {
+	m := make(map[string]reflect.Type)
+	for t, std := range stdTypes {
+		if std.kind == stdBytes {
+			m[t.PkgPath()+"."+t.Name()] = t
+		}
+	}
+	return m
+}()
+
+// described decode a value of s as generic go data, see DecodeDescribed.
+func (decoder *Decoder) described(s *Schema) interface{} {
+	switch s.Codec {
+	case CodecTime:
+		return decoder.time()
+	case CodecBigInt:
+		neg := decoder.Bool()
+		x := new(mathbig.Int).SetBytes(decoder.bytes())
+		if neg {
+			x.Neg(x)
+		}
+		return x
+	case CodecBytes:
+		b := decoder.bytes()
+		if t, ok := stdTypeNames[s.Name]; ok {
+			v := reflect.New(t)
+			if err := stdTypes[t].unmarshal(v.Interface(), b); err != nil {
+				panic(fmt.Errorf("binary.Decoder.Value: %v", err))
+			}
+			return v.Elem().Interface()
+		}
+		return append([]byte(nil), b...)
+	}
+
+	switch s.Kind {
+	case reflect.Bool:
+		return decoder.Bool()
+	case reflect.Int:
+		return decoder.Int()
+	case reflect.Uint:
+		return decoder.Uint()
+	case reflect.Int8:
+		return decoder.Int8()
+	case reflect.Int16:
+		return decoder.Int16(s.Packed)
+	case reflect.Int32:
+		return decoder.Int32(s.Packed)
+	case reflect.Int64:
+		x := decoder.Int64(s.Packed)
+		if s.Name == "time.Duration" {
+			return time.Duration(x)
+		}
+		return x
+	case reflect.Uint8:
+		return decoder.Uint8()
+	case reflect.Uint16:
+		return decoder.Uint16(s.Packed)
+	case reflect.Uint32:
+		return decoder.Uint32(s.Packed)
+	case reflect.Uint64:
+		return decoder.Uint64(s.Packed)
+	case reflect.Float32:
+		return decoder.Float32()
+	case reflect.Float64:
+		return decoder.Float64()
+	case reflect.Complex64:
+		return decoder.Complex64()
+	case reflect.Complex128:
+		return decoder.Complex128()
+	case reflect.String:
+		return decoder.String()
+	case reflect.Slice, reflect.Array:
+		return decoder.describedSlice(s)
+	case reflect.Map:
+		return decoder.describedMap(s)
+	case reflect.Ptr:
+		if !decoder.Bool() {
+			return nil
+		}
+		return decoder.described(s.Elem)
+	case reflect.Struct:
+		return decoder.describedStruct(s)
+	case reflect.Interface:
+		return decoder.describedIface(s)
+	}
+	panic(errBadSchema)
+}
+
+func (decoder *Decoder) describedSlice(s *Schema) interface{} {
+	decoder.enter()
+	defer decoder.leave()
+	elem := s.Elem
+	if elem.Codec == CodecNone {
+		switch elem.Kind {
+		case reflect.Bool: //bool array
+			n := decoder.SliceLen(1)
+			r := make([]interface{}, n)
+			var b []byte
+			for i := range r {
+				if i%8 == 0 {
+					b = decoder.reserve(1)
+				}
+				r[i] = b[0]&(1<<uint(i%8)) != 0
+			}
+			return r
+		case reflect.Uint8:
+			n := decoder.SliceLen(8)
+			return append([]byte(nil), decoder.reserve(n)...)
+		}
+	}
+	n := decoder.SliceLen(elem.bits)
+	r := make([]interface{}, n)
+	i, offset := 0, decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, indexPath(i), nil, offset)
+		}
+	}()
+	for ; i < n; i++ {
+		offset = decoder.Consumed()
+		r[i] = decoder.described(elem)
+	}
+	return r
+}
+
+func (decoder *Decoder) describedMap(s *Schema) interface{} {
+	decoder.enter()
+	defer decoder.leave()
+	n := decoder.MapLen(s.Key.bits + s.Elem.bits)
+	var strMap map[string]interface{}
+	var anyMap map[interface{}]interface{}
+	var pairs []interface{}
+	switch k := s.Key; {
+	case k.Codec == CodecNone && k.Kind == reflect.String:
+		strMap = make(map[string]interface{}, n)
+	case k.Codec == CodecNone && k.Kind >= reflect.Bool && k.Kind <= reflect.Complex128:
+		anyMap = make(map[interface{}]interface{}, n)
+	default:
+		pairs = make([]interface{}, 0, n)
+	}
+
+	i, offset := 0, decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, keyPath(i, reflect.Value{}), nil, offset)
+		}
+	}()
+	for ; i < n; i++ {
+		offset = decoder.Consumed()
+		k := decoder.described(s.Key)
+		v := decoder.described(s.Elem)
+		switch {
+		case strMap != nil:
+			strMap[k.(string)] = v
+		case anyMap != nil:
+			anyMap[k] = v
+		default:
+			pairs = append(pairs, [2]interface{}{k, v})
+		}
+	}
+	switch {
+	case strMap != nil:
+		return strMap
+	case anyMap != nil:
+		return anyMap
+	}
+	return pairs
+}
+
+func (decoder *Decoder) describedStruct(s *Schema) interface{} {
+	decoder.enter()
+	defer decoder.leave()
+	r := make(map[string]interface{}, len(s.Fields))
+	name, offset := "", decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			if name == "" {
+				panic(e)
+			}
+			tracePath(e, "."+name, nil, offset)
+		}
+	}()
+	if !s.Versioned {
+		for _, f := range s.Fields {
+			name, offset = f.Name, decoder.Consumed()
+			r[f.Name] = decoder.described(f.Type)
+		}
+		return r
+	}
+
+	for { //see decodeVersioned
+		name = ""
+		id, _ := decoder.Uvarint()
+		if id == 0 {
+			return r
+		}
+		size := decoder.length("", 0, 8)
+		var f *SchemaField
+		for i := range s.Fields {
+			if s.Fields[i].ID == id {
+				f = &s.Fields[i]
+				break
+			}
+		}
+		if f == nil {
+			decoder.Skip(size)
+			continue
+		}
+		name, offset = f.Name, decoder.Consumed()
+		sub := Decoder{limits: decoder.limits, depth: decoder.depth, strict: decoder.strict, zeroCopy: decoder.aliasing()}
+		sub.endian = decoder.endian
+		sub.offset = offset
+		sub.start = decoder.start
+		sub.buff = decoder.reserve(size)
+		sub.resetBoolCoder()
+		r[f.Name] = sub.described(f.Type)
+		if sub.pos != size {
+			panic(fmt.Errorf("binary.Decoder.Value: decoded %d bytes of field id=%d, but size is %d", sub.pos, id, size))
+		}
+	}
+}
+
+func (decoder *Decoder) describedIface(s *Schema) interface{} {
+	decoder.enter()
+	defer decoder.leave()
+	var c *SchemaType
+	switch tag, _ := decoder.Uvarint(); tag {
+	case ifaceNil:
+		return nil
+	case ifaceName:
+		name := decoder.String()
+		for i := range s.Types {
+			if t := &s.Types[i]; !t.HasID && t.Name == name {
+				c = t
+			}
+		}
+		if c == nil {
+			panic(fmt.Errorf("binary.Decoder.Value: undescribed interface type name %q", name))
+		}
+	default:
+		for i := range s.Types {
+			if t := &s.Types[i]; t.HasID && uint64(t.ID) == tag-ifaceIDBase {
+				c = t
+			}
+		}
+		if c == nil {
+			panic(fmt.Errorf("binary.Decoder.Value: undescribed interface type id %d", tag-ifaceIDBase))
+		}
+	}
+	offset := decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, ".("+c.Name+")", nil, offset)
+		}
+	}()
+	return decoder.described(c.Type)
+}
@@ -0,0 +1,172 @@
+package binary
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	mathbig "math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type describedNode struct {
+	V int16 `binary:"packed"`
+	W *int16
+}
+
+type describedStruct struct {
+	A     int8
+	B     bool
+	C     []uint32 `binary:"packed"`
+	D     [3]bool
+	E     []byte
+	F     map[string]uint16
+	G     map[[2]int]string
+	H     *describedNode
+	I     *string
+	J     testEvent
+	K     []testEvent
+	L     time.Time
+	M     time.Duration
+	N     *mathbig.Int
+	O     point
+	P     recordV2
+	Q     float32
+	R     complex64
+	S     string
+	T     bool
+	Skip  int `binary:"ignore"`
+	empty int
+}
+
+func TestDescribed(t *testing.T) {
+	if err := RegStruct((*describedStruct)(nil)); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1e9, 0).UTC()
+	data := &describedStruct{
+		A: -1,
+		B: true,
+		C: []uint32{1, 300},
+		D: [3]bool{true, false, true},
+		E: []byte("e"),
+		F: map[string]uint16{"f": 6},
+		G: map[[2]int]string{{1, 2}: "g"},
+		H: &describedNode{V: 1, W: new(int16)},
+		J: testEventCreated{ID: 1, Name: "j", Ok: true},
+		K: []testEvent{&testEventDeleted{ID: 2}, nil},
+		L: now,
+		M: time.Second,
+		N: mathbig.NewInt(-12),
+		O: point{1, 2},
+		P: recordV2{A: 1, E: 5, G: &recordV1{B: "b"}},
+		Q: 1.5,
+		R: 2i,
+		S: "s",
+		T: true,
+	}
+	b, err := EncodeDescribed(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, s, err := DecodeDescribed(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	need := map[string]interface{}{
+		"A": int8(-1),
+		"B": true,
+		"C": []interface{}{uint32(1), uint32(300)},
+		"D": []interface{}{true, false, true},
+		"E": []byte("e"),
+		"F": map[string]interface{}{"f": uint16(6)},
+		"G": []interface{}{[2]interface{}{[]interface{}{1, 2}, "g"}},
+		"H": map[string]interface{}{"V": int16(1), "W": int16(0)},
+		"I": nil,
+		"J": map[string]interface{}{"ID": uint32(1), "Name": "j", "Ok": true},
+		"K": []interface{}{map[string]interface{}{"ID": uint32(2), "Reason": nil}, nil},
+		"L": now,
+		"M": time.Second,
+		"N": mathbig.NewInt(-12),
+		"O": []byte{1, 2},
+		"P": map[string]interface{}{"D": []interface{}{}, "C": false, "A": 1, "E": int32(5), "F": "",
+			"G": map[string]interface{}{"A": 0, "B": "b", "C": false}},
+		"Q": float32(1.5),
+		"R": complex64(2i),
+		"S": "s",
+		"T": true,
+	}
+	if !reflect.DeepEqual(x, need) {
+		t.Errorf("got %#v\nneed %#v", x, need)
+	}
+
+	//Schema is the same as SchemaOf
+	if need, err := SchemaOf(reflect.TypeOf(data).Elem()); err != nil || !reflect.DeepEqual(s, need) {
+		t.Errorf("got Schema %+v\nneed %+v %v", s, need, err)
+	}
+	if s.Name != "github.com/vipally/binary.describedStruct" || !s.Fields[2].Type.Elem.Packed || s.Fields[0].Type.Packed {
+		t.Errorf("got Schema %+v", s)
+	}
+
+	//payload after Schema is decoded by Decode
+	payload, err := SkipSchema(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r describedStruct
+	if err := Decode(payload, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.N.Cmp(data.N) != 0 {
+		t.Errorf("got %v, need %v", r.N, data.N)
+	}
+	r.N = data.N
+	if !reflect.DeepEqual(&r, data) {
+		t.Errorf("got %#v\nneed %#v", r, data)
+	}
+}
+
+func TestDescribedError(t *testing.T) {
+	if _, err := EncodeDescribed(func() {}, nil); err == nil {
+		t.Error("EncodeDescribed(func) got nil error")
+	}
+	if _, _, err := DecodeDescribed([]byte("BDS\x02")); err == nil {
+		t.Error("unsupported version got nil error")
+	}
+	if _, err := SkipSchema([]byte{1, 2, 3, 4}); err == nil {
+		t.Error("missing schema got nil error")
+	}
+
+	//recursive struct fields that consume no bytes
+	b := []byte(schemaMagic)
+	b = append(b, 1, schemaKindCode(reflect.Struct), 0, 0, 0, 1, 1, 'A', 0, 0)
+	if _, _, err := DecodeDescribed(b); err == nil {
+		t.Error("recursive struct got nil error")
+	}
+
+	//pointer with codec has no element
+	b = append([]byte(schemaMagic), 1, schemaKindCode(reflect.Ptr), 0, uint8(CodecBytes), 0)
+	if _, _, err := DecodeDescribed(b); err == nil {
+		t.Error("pointer with codec and no value got nil error")
+	}
+	if s, _, err := DecodeSchema(b); err != nil || s.Kind != reflect.Ptr || s.Elem != nil {
+		t.Errorf("DecodeSchema of pointer with codec got %+v %v", s, err)
+	}
+	if err := Dump(ioutil.Discard, b, nil); err == nil {
+		t.Error("Dump of pointer with codec and no value got nil error")
+	}
+
+	//truncated input
+	b, _ = EncodeDescribed([]string{"a", "b"}, nil)
+	for i := 0; i < len(b); i++ {
+		_, _, err := DecodeDescribed(b[:i])
+		var e *DecodeError
+		if !errors.As(err, &e) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("truncated at %d got %v", i, err)
+		}
+	}
+	if x, _, err := DecodeDescribed(b); err != nil || !reflect.DeepEqual(x, []interface{}{"a", "b"}) {
+		t.Errorf("got %#v %v", x, err)
+	}
+}
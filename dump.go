@@ -0,0 +1,342 @@
+// annotated hex dump of encoded data.
+
+package binary
+
+import (
+	"fmt"
+	"io"
+	mathbig "math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Dump writes annotated hex dump of encoded data of Schema s to w.
+// If s is nil, data must be self-describing data encoded by EncodeDescribed.
+//
+// Every line is a value or a part of it: offset, number of bytes, the bytes,
+// path and the decoded value. Lengths, nil flags, type tags and field ids
+// have their own lines. Bools are dumped with the bit position in their byte,
+// number of bytes of a bool is 1 if it begins a new byte, otherwise 0.
+func Dump(w io.Writer, data []byte, s *Schema) (err error) {
+	var decoder Decoder
+	decoder.Init(data, DefaultEndian)
+	d := &dumper{decoder: &decoder, data: data, w: w}
+	defer func() {
+		if e := recover(); e != nil {
+			err = decoder.errorOf(e.(error), nil)
+			if p, ok := e.(*pathError); ok && s != nil {
+				err.(*DecodeError).Path = p.pathFrom(s.Name)
+			}
+		}
+		if err == nil {
+			err = d.err
+		}
+	}()
+	if s == nil {
+		s = decoder.schema()
+		d.line(0, "(schema)", s.Name)
+	}
+	decoder.start = decoder.Consumed()
+	name := s.Name[strings.LastIndex(s.Name, "/")+1:]
+	d.value(s, name)
+	if decoder.pos < len(data) {
+		start := decoder.Consumed()
+		decoder.pos = len(data)
+		d.line(start, "(trailing)", "not decoded")
+	}
+	return nil
+}
+
+// dumper walks encoded data by Schema and writes dump lines.
+type dumper struct {
+	decoder *Decoder //decoder of current value
+	data    []byte   //the whole data
+	w       io.Writer
+	boolAt  int64 //offset of current bool byte
+	err     error //the first write error
+}
+
+// maxDumpBytes is the max number of bytes to show in a line.
+const maxDumpBytes = 8
+
+// line writes a line of bytes from start to current offset.
+func (d *dumper) line(start int64, path string, value string) {
+	d.lineOf(start, d.decoder.Consumed()-start, path, value)
+}
+
+func (d *dumper) lineOf(start int64, size int64, path string, value string) {
+	b := d.data[start : start+size]
+	hex := ""
+	for i, c := range b {
+		if i == maxDumpBytes {
+			hex += " .."
+			break
+		}
+		if i > 0 {
+			hex += " "
+		}
+		hex += strconv.FormatUint(uint64(c)>>4, 16) + strconv.FormatUint(uint64(c)&0xf, 16)
+	}
+	if _, err := fmt.Fprintf(d.w, "%06x %5d  %-26s %s  %s\n", start, size, hex, path, value); err != nil && d.err == nil {
+		d.err = err
+	}
+}
+
+// bool dump a bool with it's bit position.
+func (d *dumper) bool(path string, note func(bool) string) bool {
+	bit, size := d.decoder.boolBit, int64(0) //size 0 if the bool byte is before
+	if bit == 0 {
+		d.boolAt, size = d.decoder.Consumed(), 1
+	}
+	x := d.decoder.Bool()
+	b := d.data[d.boolAt : d.boolAt+1]
+	d.lineOf(d.boolAt, size, path, fmt.Sprintf("%s (bit %d of %02x)", note(x), bit, b[0]))
+	return x
+}
+
+func boolNote(x bool) string {
+	return "bool " + strconv.FormatBool(x)
+}
+
+func nilNote(x bool) string {
+	if x {
+		return "not nil"
+	}
+	return "nil"
+}
+
+// length dump a uvarint length.
+func (d *dumper) length(label string, limit string, max int, bits int) int {
+	start := d.decoder.Consumed()
+	n := d.decoder.length(limit, max, bits)
+	d.line(start, label, strconv.Itoa(n))
+	return n
+}
+
+// value dump a value of s.
+func (d *dumper) value(s *Schema, path string) {
+	decoder := d.decoder
+	start := decoder.Consumed()
+	switch s.Codec {
+	case CodecTime:
+		d.time(path)
+		return
+	case CodecBigInt:
+		neg := d.bool(path+" (sign)", func(x bool) string { return "negative " + strconv.FormatBool(x) })
+		n := d.length(path+" (len)", "MaxStringLen", decoder.limits.MaxStringLen, 8)
+		start = decoder.Consumed()
+		x := new(mathbig.Int).SetBytes(decoder.reserve(n))
+		if neg {
+			x.Neg(x)
+		}
+		d.line(start, path, "big.Int "+x.String())
+		return
+	case CodecBytes:
+		n := d.length(path+" (len)", "MaxStringLen", decoder.limits.MaxStringLen, 8)
+		start = decoder.Consumed()
+		b := decoder.reserve(n)
+		value := fmt.Sprintf("%d bytes of %s", n, s.Name)
+		if t, ok := stdTypeNames[s.Name]; ok {
+			v := reflect.New(t)
+			if err := stdTypes[t].unmarshal(v.Interface(), b); err != nil {
+				panic(fmt.Errorf("binary.Decoder.Value: %v", err))
+			}
+			value = fmt.Sprintf("%s %v", s.Name, v.Interface())
+		}
+		d.line(start, path, value)
+		return
+	}
+
+	switch s.Kind {
+	case reflect.Bool:
+		d.bool(path, boolNote)
+	case reflect.String:
+		n := d.length(path+" (len)", "MaxStringLen", decoder.limits.MaxStringLen, 8)
+		start = decoder.Consumed()
+		x := string(decoder.reserve(n))
+		d.line(start, path, strconv.Quote(x))
+	case reflect.Slice, reflect.Array:
+		d.slice(s, path)
+	case reflect.Map:
+		d.mapValue(s, path)
+	case reflect.Ptr:
+		if d.bool(path+" (nil flag)", nilNote) {
+			d.value(s.Elem, path)
+		}
+	case reflect.Struct:
+		d.structValue(s, path)
+	case reflect.Interface:
+		d.iface(s, path)
+	default: //numbers
+		x := decoder.described(s)
+		kind := s.Kind.String()
+		if s.Name != "" {
+			kind = s.Name
+		}
+		if s.Packed || s.Kind == reflect.Int || s.Kind == reflect.Uint {
+			kind += " varint"
+		}
+		d.line(start, path, fmt.Sprintf("%s %v", kind, x))
+	}
+}
+
+// time dump parts of a time.Time, see encoder.time.
+func (d *dumper) time(path string) {
+	decoder := d.decoder
+	start := decoder.Consumed()
+	pos, boolBit, boolValue := decoder.pos, decoder.boolBit, decoder.boolValue
+	t := decoder.time()
+	decoder.pos, decoder.boolBit, decoder.boolValue = pos, boolBit, boolValue //dump again by parts
+	d.lineOf(start, 0, path, "time.Time "+t.String())
+
+	sec, _ := decoder.Varint()
+	d.line(start, path+" (sec)", strconv.FormatInt(sec, 10))
+	start = decoder.Consumed()
+	nsec, _ := decoder.Uvarint()
+	d.line(start, path+" (nsec)", strconv.FormatUint(nsec, 10))
+	if d.bool(path+" (zone flag)", boolNote) {
+		start = decoder.Consumed()
+		offset, _ := decoder.Varint()
+		d.line(start, path+" (zone offset)", strconv.FormatInt(offset, 10))
+	}
+}
+
+func (d *dumper) slice(s *Schema, path string) {
+	decoder := d.decoder
+	decoder.enter()
+	defer decoder.leave()
+	elem := s.Elem
+	if elem.Codec == CodecNone {
+		switch elem.Kind {
+		case reflect.Bool: //bits in bytes of it's own
+			n := d.length(path+" (len)", "MaxSliceLen", decoder.limits.MaxSliceLen, 1)
+			var at int64
+			for i := 0; i < n; i++ {
+				size := int64(0)
+				if i%8 == 0 {
+					at, size = decoder.Consumed(), 1
+					decoder.reserve(1)
+				}
+				x := d.data[at]&(1<<uint(i%8)) != 0
+				d.lineOf(at, size, path+indexPath(i), fmt.Sprintf("bool %v (bit %d of %02x)", x, i%8, d.data[at]))
+			}
+			return
+		case reflect.Uint8:
+			n := d.length(path+" (len)", "MaxSliceLen", decoder.limits.MaxSliceLen, 8)
+			start := decoder.Consumed()
+			decoder.reserve(n)
+			d.line(start, path, fmt.Sprintf("%d bytes", n))
+			return
+		}
+	}
+	n := d.length(path+" (len)", "MaxSliceLen", decoder.limits.MaxSliceLen, elem.bits)
+	for i := 0; i < n; i++ {
+		d.value(elem, path+indexPath(i))
+	}
+}
+
+func (d *dumper) mapValue(s *Schema, path string) {
+	decoder := d.decoder
+	decoder.enter()
+	defer decoder.leave()
+	n := d.length(path+" (len)", "MaxMapLen", decoder.limits.MaxMapLen, s.Key.bits+s.Elem.bits)
+	for i := 0; i < n; i++ {
+		entry := path + "[#" + strconv.Itoa(i) + "]"
+		d.value(s.Key, entry+" (key)")
+		d.value(s.Elem, entry)
+	}
+}
+
+func (d *dumper) structValue(s *Schema, path string) {
+	decoder := d.decoder
+	decoder.enter()
+	defer decoder.leave()
+	if !s.Versioned {
+		for _, f := range s.Fields {
+			d.value(f.Type, path+"."+f.Name)
+		}
+		return
+	}
+
+	for { //see decodeVersioned
+		start := decoder.Consumed()
+		id, _ := decoder.Uvarint()
+		if id == 0 {
+			d.line(start, path+" (end)", "0")
+			return
+		}
+		var f *SchemaField
+		for i := range s.Fields {
+			if s.Fields[i].ID == id {
+				f = &s.Fields[i]
+				break
+			}
+		}
+		field := path + ".#" + strconv.FormatUint(id, 10)
+		if f != nil {
+			field = path + "." + f.Name
+		}
+		d.line(start, field+" (id)", strconv.FormatUint(id, 10))
+		size := d.length(field+" (size)", "", 0, 8)
+		if f == nil {
+			start = decoder.Consumed()
+			decoder.Skip(size)
+			d.line(start, field, "unknown field")
+			continue
+		}
+
+		sub := Decoder{limits: decoder.limits, depth: decoder.depth, strict: decoder.strict}
+		sub.endian = decoder.endian
+		sub.offset = decoder.Consumed()
+		sub.start = decoder.start
+		sub.buff = decoder.reserve(size)
+		sub.resetBoolCoder()
+		d.decoder = &sub
+		d.value(f.Type, field)
+		d.decoder = decoder
+		if sub.pos != size {
+			panic(fmt.Errorf("binary.Decoder.Value: decoded %d bytes of field id=%d, but size is %d", sub.pos, id, size))
+		}
+	}
+}
+
+func (d *dumper) iface(s *Schema, path string) {
+	decoder := d.decoder
+	decoder.enter()
+	defer decoder.leave()
+	start := decoder.Consumed()
+	var c *SchemaType
+	switch tag, _ := decoder.Uvarint(); tag {
+	case ifaceNil:
+		d.line(start, path+" (type)", "nil")
+		return
+	case ifaceName:
+		name := decoder.String()
+		for i := range s.Types {
+			if t := &s.Types[i]; !t.HasID && t.Name == name {
+				c = t
+			}
+		}
+		d.line(start, path+" (type)", "name "+strconv.Quote(name))
+		if c == nil {
+			panic(fmt.Errorf("binary.Decoder.Value: undescribed interface type name %q", name))
+		}
+	default:
+		id := tag - ifaceIDBase
+		for i := range s.Types {
+			if t := &s.Types[i]; t.HasID && uint64(t.ID) == id {
+				c = t
+			}
+		}
+		d.line(start, path+" (type)", "id "+strconv.FormatUint(id, 10))
+		if c == nil {
+			panic(fmt.Errorf("binary.Decoder.Value: undescribed interface type id %d", id))
+		}
+	}
+	name := c.Name
+	if c.HasID {
+		name = strconv.FormatUint(uint64(c.ID), 10)
+	}
+	d.value(c.Type, path+".("+name+")")
+}
@@ -0,0 +1,97 @@
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dumpStruct struct {
+	A int8
+	B bool
+	C []uint32 `binary:"packed"`
+	D *int16
+	E bool
+	F string
+	G [3]bool
+	H time.Time
+	I recordV1
+}
+
+func TestDump(t *testing.T) {
+	data := &dumpStruct{
+		A: -1,
+		B: true,
+		C: []uint32{1, 300},
+		E: true,
+		F: "f",
+		G: [3]bool{true, false, true},
+		H: time.Unix(1e9, 0).UTC(),
+		I: recordV1{A: 1, B: "b"},
+	}
+	b, err := EncodeDescribed(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w bytes.Buffer
+	if err := Dump(&w, b, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	sum := 0
+	for _, line := range lines { //every byte is dumped once
+		fields := strings.Fields(line)
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			t.Fatalf("bad line %q", line)
+		}
+		sum += n
+	}
+	if sum != len(b) {
+		t.Errorf("dumped %d bytes, want %d:\n%s", sum, len(b), w.String())
+	}
+	for _, want := range []string{
+		"(schema)  github.com/vipally/binary.dumpStruct",
+		"binary.dumpStruct.A  int8 -1",
+		"binary.dumpStruct.B  bool true (bit 0 of 05)",
+		"binary.dumpStruct.C (len)  2",
+		"ac 02                      binary.dumpStruct.C[1]  uint32 varint 300",
+		"0000bb     0                             binary.dumpStruct.D (nil flag)  nil (bit 1 of 05)",
+		"binary.dumpStruct.E  bool true (bit 2 of 05)",
+		`binary.dumpStruct.F  "f"`,
+		"binary.dumpStruct.G[2]  bool true (bit 2 of 05)",
+		"binary.dumpStruct.H  time.Time 2001-09-09 01:46:40 +0000 UTC",
+		"binary.dumpStruct.H (zone flag)  bool false (bit 3 of 05)",
+		"binary.dumpStruct.I.B (id)  2",
+		"binary.dumpStruct.I.B (size)  2",
+		"binary.dumpStruct.I.C  bool false (bit 0 of 00)",
+		"binary.dumpStruct.I (end)  0",
+	} {
+		if !strings.Contains(w.String(), want) {
+			t.Errorf("missing %q in dump:\n%s", want, w.String())
+		}
+	}
+
+	//not self-describing data with trailing bytes
+	s, err := SchemaOf(reflect.TypeOf(dumpStruct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = Encode(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Reset()
+	if err := Dump(&w, append(b, 0xff), s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(w.String(), "ff                         (trailing)  not decoded\n") {
+		t.Errorf("missing trailing bytes in dump:\n%s", w.String())
+	}
+	if err := Dump(&w, b[:len(b)-1], s); err == nil {
+		t.Error("Dump truncated data: have err == nil, want non-nil")
+	}
+}
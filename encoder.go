@@ -1,9 +1,13 @@
 package binary
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"reflect"
+	"unsafe"
 )
 
 // NewEncoder make a new Encoder object with buffer size.
@@ -28,9 +32,59 @@ func NewEncoderEndian(size int, endian Endian) *Encoder {
 	return p
 }
 
+// NewEncoderAutoGrow make a new Encoder object with initial buffer size.
+// The buffer will grow automatically when it is not enough,
+// so that it will never panic for lack of space.
+func NewEncoderAutoGrow(size int) *Encoder {
+	p := NewEncoder(size)
+	p.autoGrow = true
+	return p
+}
+
+// NewStreamEncoder make a new Encoder object that writes to w.
+// Encoded bytes are buffered and written to w in bounded chunks while encoding,
+// Flush must be called to write the remaining bytes after encoding.
+func NewStreamEncoder(w io.Writer, endian Endian) *Encoder {
+	p := NewEncoderEndian(defaultStreamBufferSize, endian)
+	p.writer = w
+	return p
+}
+
+const (
+	minGrowSize             = 64   //minimum buffer size after an auto grow
+	defaultStreamBufferSize = 4096 //buffer size of stream Encoder
+)
+
+// modes of Encoder.Bool
+const (
+	boolPatch  = iota //set bits of bool byte in buffer
+	boolRecord        //set bits of bool byte in buffer and record the bool bytes
+	boolReplay        //write recorded bool bytes and never modify them
+)
+
+// errRestart is used to stop encoding a value that can not be flushed
+var errRestart = errors.New("binary.Encoder: restart stream encoding")
+
 // Encoder is used to encode go data to byte array.
 type Encoder struct {
 	coder
+	autoGrow      bool      //grow buffer automatically instead of panic
+	canonical     bool      //encode map entries in canonical order
+	stdCompatible bool      //encode as encoding/binary, see stdcompat.go
+	writer        io.Writer //for encode to writer only
+
+	written     int64  //bytes that have been flushed to writer
+	discard     int64  //bytes need not flush because they have been written
+	restartable bool   //if it is encoding a value that can be restarted
+	boolMode    int    //mode of Bool
+	bools       []byte //recorded bool bytes
+	boolIdx     int    //index of next recorded bool byte to replay
+
+	maps   []mapEntries //recorded map entries, to replay maps in the same order
+	mapIdx int          //index of next recorded map entries to replay
+
+	start  int64 //offset when current Value begins
+	pooled bool  //acquired by AcquireEncoder and not released yet
 }
 
 // Init initialize Encoder with buffer size and endian.
@@ -51,6 +105,146 @@ func (encoder *Encoder) ResizeBuffer(size int) bool {
 	return ok
 }
 
+// SetAutoGrow enable or disable the auto grow mode of Encoder.
+// In auto grow mode, the buffer will grow amortized like bytes.Buffer when
+// it is not enough, instead of panic.
+func (encoder *Encoder) SetAutoGrow(enable bool) {
+	encoder.autoGrow = enable
+}
+
+// Skip ignore the next size of bytes for encoding and set skiped bytes to 0.
+// It will return -1 if size <= 0 or space not enough.
+func (encoder *Encoder) Skip(size int) int {
+	if size > 0 {
+		encoder.makeRoom(size)
+	}
+	return encoder.coder.Skip(size)
+}
+
+// Flush writes all buffered bytes to the writer of stream Encoder.
+// The bool byte that is being filled is finished by Flush,
+// following bools will be encoded into a new byte.
+// It does nothing if Encoder is not created by NewStreamEncoder.
+func (encoder *Encoder) Flush() (err error) {
+	if encoder.writer == nil {
+		return nil
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			err = e.(error)
+		}
+	}()
+
+	encoder.resetBoolCoder()
+	encoder.flush()
+	return nil
+}
+
+// reserve returns next size bytes for encoding.
+// It will flush buffered bytes for stream Encoder and grow the buffer
+// in auto grow mode, or panic if not enough space.
+func (encoder *Encoder) reserve(size int) []byte {
+	encoder.makeRoom(size)
+	return encoder.coder.reserve(size)
+}
+
+// makeRoom confirm that there is at least size bytes after pos,
+// by flushing buffered bytes to writer or growing the buffer.
+func (encoder *Encoder) makeRoom(size int) {
+	if encoder.pos+size <= len(encoder.buff) {
+		return
+	}
+	if encoder.writer != nil {
+		encoder.flush()
+		if encoder.pos+size <= len(encoder.buff) {
+			return
+		}
+		if encoder.restartable && encoder.boolPending() {
+			panic(errRestart) //open bool byte blocks flushing, see streamValue
+		}
+	} else if !encoder.autoGrow {
+		return //coder.reserve will panic
+	}
+
+	n := 2*len(encoder.buff) + size
+	if n < minGrowSize {
+		n = minGrowSize
+	}
+	buff := make([]byte, n)
+	copy(buff, encoder.buff[:encoder.pos])
+	encoder.buff = buff
+}
+
+// flush writes buffered bytes to writer and move the rest to the
+// beginning of buffer.
+// The bool byte that is being filled is kept in buffer,
+// because Bool will modify it later.
+// It will panic if writer returns error.
+func (encoder *Encoder) flush() {
+	n := encoder.pos
+	pending := encoder.boolPending()
+	if pending {
+		n = encoder.boolPos
+	}
+	if n <= 0 {
+		return
+	}
+	b := encoder.buff[:n]
+	if d := encoder.discard; d > 0 {
+		if d > int64(n) {
+			d = int64(n)
+		}
+		b = b[d:]
+		encoder.discard -= d
+	}
+	if len(b) > 0 {
+		if _, err := encoder.writer.Write(b); err != nil {
+			panic(err)
+		}
+	}
+	encoder.written += int64(n)
+	encoder.pos = copy(encoder.buff, encoder.buff[n:encoder.pos])
+	if pending {
+		encoder.boolPos -= n
+	}
+}
+
+// boolPending returns if there is a bool byte in buffer that will be modified later.
+func (encoder *Encoder) boolPending() bool {
+	return encoder.boolMode != boolReplay && encoder.boolBit != 0 &&
+		encoder.boolPos >= 0 && encoder.boolPos < encoder.pos
+}
+
+// writeString copy s to Encoder buffer.
+// Stream Encoder writes large s to writer directly instead of growing buffer.
+func (encoder *Encoder) writeString(s string) {
+	if encoder.writer != nil && encoder.pos+len(s) > len(encoder.buff) {
+		encoder.flush()
+		if encoder.pos == 0 && encoder.discard == 0 && len(s) > len(encoder.buff) {
+			if _, err := io.WriteString(encoder.writer, s); err != nil {
+				panic(err)
+			}
+			encoder.written += int64(len(s))
+			return
+		}
+	}
+	copy(encoder.reserve(len(s)), s)
+}
+
+// writeBytes copy b to Encoder buffer.
+// Stream Encoder writes b in chunks as large as the buffer can hold.
+func (encoder *Encoder) writeBytes(b []byte) {
+	for encoder.writer != nil && encoder.pos+len(b) > len(encoder.buff) {
+		n := len(encoder.buff) - encoder.pos
+		if n == 0 { //reserve flushes the buffer
+			n = 1
+		}
+		copy(encoder.reserve(n), b)
+		b = b[n:]
+	}
+	copy(encoder.reserve(len(b)), b)
+}
+
 // Bool encode a bool value to Encoder buffer.
 // It will panic if buffer is not enough.
 func (encoder *Encoder) Bool(x bool) {
@@ -58,10 +252,23 @@ func (encoder *Encoder) Bool(x bool) {
 		b := encoder.reserve(1)
 		b[0] = 0
 		encoder.boolPos = encoder.pos - 1
+		switch encoder.boolMode {
+		case boolRecord:
+			encoder.bools = append(encoder.bools, 0)
+		case boolReplay:
+			b[0] = encoder.bools[encoder.boolIdx]
+			encoder.boolIdx++
+		}
 	}
 
 	if mask := byte(1 << encoder.boolBit); x {
-		encoder.buff[encoder.boolPos] |= mask
+		switch encoder.boolMode {
+		case boolPatch:
+			encoder.buff[encoder.boolPos] |= mask
+		case boolRecord:
+			encoder.buff[encoder.boolPos] |= mask
+			encoder.bools[len(encoder.bools)-1] |= mask
+		}
 	}
 	encoder.boolBit = (encoder.boolBit + 1) % 8
 }
@@ -172,11 +379,8 @@ func (encoder *Encoder) Complex128(x complex128) {
 // String encode a string value to Encoder buffer.
 // It will panic if buffer is not enough.
 func (encoder *Encoder) String(x string) {
-	_b := []byte(x)
-	size := len(_b)
-	encoder.Uvarint(uint64(size))
-	buff := encoder.reserve(size)
-	copy(buff, _b)
+	encoder.Uvarint(uint64(len(x)))
+	encoder.writeString(x)
 }
 
 // Int encode an int value to Encoder buffer.
@@ -218,12 +422,16 @@ func (encoder *Encoder) Uvarint(x uint64) int {
 func (encoder *Encoder) Value(x interface{}) (err error) {
 	defer func() {
 		if e := recover(); e != nil {
-			err = e.(error)
+			err = encoder.errorOf(e.(error), x)
 		}
 	}()
 
 	encoder.resetBoolCoder() //reset bool writer
+	encoder.start = encoder.offset()
 
+	if encoder.stdCompatible {
+		return encoder.stdValue(x)
+	}
 	if encoder.fastValue(x) { //fast value path
 		return nil
 	}
@@ -237,7 +445,7 @@ func (encoder *Encoder) Value(x interface{}) (err error) {
 
 		r, err := p.Encode(encoder.buff[encoder.pos:])
 		if err == nil {
-			encoder.reserve(len(r))
+			copy(encoder.reserve(len(r)), r) //r may not share memory with buff
 		}
 		return err
 	}
@@ -246,7 +454,223 @@ func (encoder *Encoder) Value(x interface{}) (err error) {
 		panic(fmt.Errorf("unexpected BinarySizer: %s", v.Type().String()))
 	}
 
-	return encoder.value(reflect.Indirect(v), false)
+	if encoder.writer != nil {
+		err = encoder.streamValue(reflect.Indirect(v))
+	} else {
+		err = encoder.value(reflect.Indirect(v), false)
+	}
+	if err != nil {
+		return encoder.errorOf(err, x)
+	}
+	return nil
+}
+
+// offset returns number of bytes that has been encoded.
+func (encoder *Encoder) offset() int64 {
+	return encoder.written + int64(encoder.pos)
+}
+
+// errorOf returns *EncodeError of err with path of the failed value.
+// *UnsupportedTypeError of the top level value is returned directly.
+func (encoder *Encoder) errorOf(err error, x interface{}) error {
+	p, ok := err.(*pathError)
+	if !ok {
+		if _, ok := err.(*UnsupportedTypeError); ok {
+			return err
+		}
+		p = &pathError{t: rootType(x), offset: encoder.start, err: err}
+	}
+	return &EncodeError{Offset: p.offset, Type: p.t, Path: p.path(rootType(x)), Err: p.err}
+}
+
+// streamValue encode v for stream Encoder.
+// Bool bytes are modified after they are reserved, so they can not be flushed
+// until they are full. It try to encode v in one pass first. If the buffer is
+// filled up while a bool byte is open, it encodes v again in two pass:
+// the first pass only records the final value of bool bytes,
+// and the second pass writes them directly and flush freely.
+func (encoder *Encoder) streamValue(v reflect.Value) error {
+	start := encoder.written + int64(encoder.pos)
+	if err, ok := encoder.tryValue(v); ok {
+		return err
+	}
+
+	//drop bytes of v from buffer, and ignore the bytes that have been written
+	if start >= encoder.written {
+		encoder.pos = int(start - encoder.written)
+	} else {
+		encoder.discard = encoder.written - start
+		encoder.written = start
+		encoder.pos = 0
+	}
+	encoder.resetBoolCoder()
+
+	dry := *encoder
+	dry.buff = make([]byte, minGrowSize)
+	dry.pos = 0
+	dry.writer = ioutil.Discard
+	dry.discard = 0
+	dry.boolMode = boolRecord
+	dry.bools = nil
+	dry.maps = nil
+	if err := dry.value(v, false); err != nil {
+		return err
+	}
+
+	encoder.boolMode = boolReplay
+	encoder.bools = dry.bools
+	encoder.boolIdx = 0
+	encoder.maps = dry.maps
+	encoder.mapIdx = 0
+	defer func() {
+		encoder.boolMode = boolPatch
+		encoder.bools = nil
+		encoder.maps = nil
+	}()
+	return encoder.value(v, false)
+}
+
+// mapEntries are keys and values of a map, in the order they are encoded.
+// Values are taken with keys, as keys like NaN can not index the map.
+type mapEntries struct {
+	keys, values []reflect.Value
+}
+
+// mapEntriesOf returns entries of map v.
+// Entries are recorded in boolRecord mode and replayed in boolReplay mode,
+// to make sure map entries are encoded in the same order in both pass.
+// Entries are sorted in canonical mode.
+func (encoder *Encoder) mapEntriesOf(v reflect.Value, packed bool) mapEntries {
+	if encoder.boolMode == boolReplay {
+		e := encoder.maps[encoder.mapIdx]
+		encoder.mapIdx++
+		return e
+	}
+
+	e := mapEntriesOf(v)
+	if encoder.canonical {
+		sortEntries(e, packed)
+	}
+	if encoder.boolMode == boolRecord {
+		encoder.maps = append(encoder.maps, e)
+	}
+	return e
+}
+
+// mapEntriesOf returns entries of map v in the order of iteration.
+func mapEntriesOf(v reflect.Value) mapEntries {
+	e := mapEntries{
+		keys:   make([]reflect.Value, 0, v.Len()),
+		values: make([]reflect.Value, 0, v.Len()),
+	}
+	for it := v.MapRange(); it.Next(); {
+		e.keys = append(e.keys, it.Key())
+		e.values = append(e.values, it.Value())
+	}
+	return e
+}
+
+// tryValue encode v in one pass, ok is false if it need restart.
+func (encoder *Encoder) tryValue(v reflect.Value) (err error, ok bool) {
+	defer func() {
+		encoder.restartable = false
+		if e := recover(); e != nil {
+			if e != errRestart {
+				panic(e)
+			}
+			ok = false
+		}
+	}()
+	encoder.restartable = true
+	return encoder.value(v, false), true
+}
+
+// slice encode slice or array v.
+// Path of the failed element is added to error while unwinding.
+func (encoder *Encoder) slice(v reflect.Value, packed bool) {
+	if encoder.boolArray(v) >= 0 { //deal with bool array first
+		return
+	}
+	i, offset := 0, encoder.offset()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, indexPath(i), v.Type().Elem(), offset)
+		}
+	}()
+	kind := customOf(v.Type().Elem())
+	l := v.Len()
+	encoder.Uvarint(uint64(l))
+	for ; i < l; i++ {
+		offset = encoder.offset()
+		encoder.elem(v.Index(i), kind, packed)
+	}
+}
+
+// mapValue encode map v.
+// Path of the failed entry is added to error while unwinding.
+func (encoder *Encoder) mapValue(v reflect.Value, packed bool) {
+	e := encoder.mapEntriesOf(v, packed)
+	keys := e.keys
+	i, offset := 0, encoder.offset()
+	inValue := false //encoding value of entry i
+	defer func() {
+		if e := recover(); e != nil {
+			if inValue {
+				tracePath(e, keyPath(i, keys[i]), v.Type().Elem(), offset)
+			} else {
+				tracePath(e, keyPath(i, reflect.Value{}), v.Type().Key(), offset)
+			}
+		}
+	}()
+	keyKind, valueKind := customOf(v.Type().Key()), customOf(v.Type().Elem())
+	l := len(keys)
+	encoder.Uvarint(uint64(l))
+	for ; i < l; i++ {
+		key := keys[i]
+		offset, inValue = encoder.offset(), false
+		encoder.elem(key, keyKind, packed)
+		offset, inValue = encoder.offset(), true
+		encoder.elem(e.values[i], valueKind, packed)
+	}
+}
+
+// elem encode nested value v that may be custom.
+func (encoder *Encoder) elem(v reflect.Value, kind customKind, packed bool) {
+	if kind != customNone {
+		encoder.custom(v, kind)
+		return
+	}
+	if err := encoder.value(v, packed); err != nil {
+		panic(err)
+	}
+}
+
+// iface encode interface value v as type tag followed by it's concrete value.
+// It will panic if the concrete type is not registed.
+func (encoder *Encoder) iface(v reflect.Value) {
+	if v.IsNil() {
+		encoder.Uvarint(ifaceNil)
+		return
+	}
+	e := v.Elem()
+	offset := encoder.offset()
+	defer func() {
+		if err := recover(); err != nil {
+			tracePath(err, ".("+e.Type().String()+")", e.Type(), offset)
+		}
+	}()
+	info := _ifaceTypeMgr.query(e.Type())
+	if info == nil {
+		panic(fmt.Errorf("binary.Encoder.Value: unregistered type %s in interface %s", e.Type().String(), v.Type().String()))
+	}
+	if info.hasID {
+		encoder.Uvarint(uint64(info.id) + ifaceIDBase)
+	} else {
+		encoder.Uvarint(ifaceName)
+		encoder.String(info.name)
+	}
+	offset = encoder.offset()
+	encoder.elem(e, customOf(e.Type()), false)
 }
 
 func (encoder *Encoder) fastValue(x interface{}) bool {
@@ -301,78 +725,42 @@ func (encoder *Encoder) fastValue(x interface{}) bool {
 		}
 
 	case []int8:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Int8(d[i])
-		}
+		encoder.writeBytes(numbersOf(unsafe.Pointer(&d), 1))
 	case []uint8:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Uint8(d[i])
-		}
+		encoder.writeBytes(numbersOf(unsafe.Pointer(&d), 1))
 	case []int16:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Int16(d[i], false)
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 2), 2)
 	case []uint16:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Uint16(d[i], false)
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 2), 2)
 
 	case []int32:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Int32(d[i], false)
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 4), 4)
 	case []uint32:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Uint32(d[i], false)
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 4), 4)
 	case []int64:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Int64(d[i], false)
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 8), 8)
 	case []uint64:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Uint64(d[i], false)
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 8), 8)
 	case []float32:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Float32(d[i])
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 4), 4)
 	case []float64:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Float64(d[i])
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 8), 8)
 	case []complex64:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Complex64(d[i])
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 8), 4)
 	case []complex128:
-		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
-		for i := 0; i < l; i++ {
-			encoder.Complex128(d[i])
-		}
+		encoder.writeNumbers(numbersOf(unsafe.Pointer(&d), 16), 8)
 	case []string:
 		l := len(d)
 		encoder.Uvarint(uint64(len(d)))
@@ -418,6 +806,17 @@ func (encoder *Encoder) value(v reflect.Value, packed bool) error {
 	//		}
 	//	}
 
+	if p := planOf(v.Type(), packed); p != nil { //compiled plan, see plan.go
+		if ptr := planPtr(v, p); ptr != nil {
+			encoder.plan(p, ptr)
+			return nil
+		}
+	}
+	if std := stdTypeOf(v.Type()); std != nil { //standard library types
+		encoder.std(std, v)
+		return nil
+	}
+
 	switch k := v.Kind(); k {
 	case reflect.Int:
 		encoder.Int(int(v.Int()))
@@ -463,38 +862,24 @@ func (encoder *Encoder) value(v reflect.Value, packed bool) error {
 
 	case reflect.Slice, reflect.Array:
 		if !validUserType(v.Type().Elem()) { //verify array element is valid
-			return fmt.Errorf("binary.Encoder.Value: unsupported type %s", v.Type().String())
-		}
-		if encoder.boolArray(v) < 0 { //deal with bool array first
-			l := v.Len()
-			encoder.Uvarint(uint64(l))
-			for i := 0; i < l; i++ {
-				assert(encoder.value(v.Index(i), packed) == nil, "")
-			}
+			return &UnsupportedTypeError{Type: v.Type(), op: "binary.Encoder.Value"}
 		}
+		encoder.slice(v, packed)
 	case reflect.Map:
-		t := v.Type()
-		kt := t.Key()
-		vt := t.Elem()
-		if !validUserType(kt) ||
-			!validUserType(vt) { //verify map key and value type are both valid
-			return fmt.Errorf("binary.Decoder.Value: unsupported type %s", v.Type().String())
-		}
-
-		keys := v.MapKeys()
-		l := len(keys)
-		encoder.Uvarint(uint64(l))
-		for i := 0; i < l; i++ {
-			key := keys[i]
-			assert(encoder.value(key, packed) == nil, "")
-			assert(encoder.value(v.MapIndex(key), packed) == nil, "")
+		if !validUserType(v.Type().Key()) ||
+			!validUserType(v.Type().Elem()) { //verify map key and value type are both valid
+			return &UnsupportedTypeError{Type: v.Type(), op: "binary.Encoder.Value"}
 		}
+		encoder.mapValue(v, packed)
 	case reflect.Struct:
 		return queryStruct(v.Type()).encode(encoder, v)
 
+	case reflect.Interface:
+		encoder.iface(v)
+
 	case reflect.Ptr:
 		if !validUserType(v.Type()) {
-			return fmt.Errorf("binary.Encoder.Value: unsupported type %s", v.Type().String())
+			return &UnsupportedTypeError{Type: v.Type(), op: "binary.Encoder.Value"}
 		}
 		if !v.IsNil() {
 			encoder.Bool(true)
@@ -510,7 +895,7 @@ func (encoder *Encoder) value(v reflect.Value, packed bool) error {
 		//	case reflect.Invalid://BUG: it will panic to get zero.Type
 		//		return fmt.Errorf("binary.Encoder.Value: unsupported type [%s]", v.Kind().String())
 	default:
-		return fmt.Errorf("binary.Encoder.Value: unsupported type [%s]", v.Type().String())
+		return &UnsupportedTypeError{Type: v.Type(), op: "binary.Encoder.Value"}
 	}
 	return nil
 }
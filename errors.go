@@ -0,0 +1,169 @@
+// error types with the position where encoding/decoding fails.
+
+package binary
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// DecodeError is returned by Decoder if it fails to decode a value.
+// Use errors.As to check the underlying error, eg: *TruncatedError, *LimitError.
+type DecodeError struct {
+	Offset int64        // offset of the value that fails to decode
+	Type   reflect.Type // type of the value that fails to decode
+	Path   string       // path from the top level value, eg: Order.Items[3].Price
+	Err    error        // the underlying error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v (decoding %s of type %s at offset %d)", e.Err, e.Path, typeString(e.Type), e.Offset)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// EncodeError is returned by Encoder if it fails to encode a value.
+type EncodeError struct {
+	Offset int64        // offset of the value that fails to encode
+	Type   reflect.Type // type of the value that fails to encode
+	Path   string       // path from the top level value, eg: Order.Items[3].Price
+	Err    error        // the underlying error
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("%v (encoding %s of type %s at offset %d)", e.Err, e.Path, typeString(e.Type), e.Offset)
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedTypeError is returned by Encoder and Decoder for a value of unsupported type.
+// It is returned directly for the top level value, and wrapped by
+// EncodeError/DecodeError for a nested value.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+	op   string //"binary.Encoder.Value" or "binary.Decoder.Value"
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return e.op + ": unsupported type " + typeString(e.Type)
+}
+
+// TruncatedError is returned by Decoder if the input ends in the middle of a value.
+// It unwraps to io.ErrUnexpectedEOF.
+type TruncatedError struct {
+	Offset int64 // offset where more bytes are required
+	Need   int   // number of bytes required
+	Have   int   // number of bytes remain
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("binary: unexpected end of input at offset %d: need %d bytes, have %d", e.Offset, e.Need, e.Have)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+func typeString(t reflect.Type) string {
+	if t == nil {
+		return "nil"
+	}
+	return t.String()
+}
+
+// pathError is the error of a nested value while unwinding.
+// Path segments are added from the failed value to the top level value
+// by the deferred functions of nested values, so tracing path costs nothing
+// unless an error happens.
+type pathError struct {
+	segs   []string     //path segments, from the failed value
+	t      reflect.Type //type of the failed value
+	offset int64        //offset of the failed value
+	err    error        //the underlying error
+}
+
+func (e *pathError) Error() string {
+	return e.err.Error()
+}
+
+// path returns the path from root, eg: Order.Items[3].Price.
+func (e *pathError) path(root reflect.Type) string {
+	s := ""
+	if root != nil {
+		s = root.Name()
+	}
+	if s == "" {
+		s = typeString(root)
+	}
+	return e.pathFrom(s)
+}
+
+// pathFrom returns the path from root with name.
+func (e *pathError) pathFrom(name string) string {
+	s := name
+	for i := len(e.segs) - 1; i >= 0; i-- {
+		s += e.segs[i]
+	}
+	return s
+}
+
+// addPath adds path segment seg of a nested value with type t at offset to err.
+// Type and offset of the innermost value are kept.
+// Sentinel errors are returned unchanged.
+func addPath(err error, seg string, t reflect.Type, offset int64) error {
+	if err == io.EOF || err == errRestart {
+		return err
+	}
+	p, ok := err.(*pathError)
+	if !ok {
+		p = &pathError{t: t, offset: offset, err: err}
+	}
+	if seg != "" {
+		p.segs = append(p.segs, seg)
+	}
+	return p
+}
+
+// tracePath is deferred by nested values to add path segment of the failed value.
+// e is the recovered panic, it panics again with the path added.
+func tracePath(e interface{}, seg string, t reflect.Type, offset int64) {
+	err, ok := e.(error)
+	if !ok {
+		panic(e)
+	}
+	panic(addPath(err, seg, t, offset))
+}
+
+// indexPath returns path segment of element i.
+func indexPath(i int) string {
+	if i < 0 { //the value itself
+		return ""
+	}
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// keyPath returns path segment of map entry i with key.
+func keyPath(i int, key reflect.Value) string {
+	switch {
+	case !key.IsValid(): //the key of i-th entry
+		return "[#" + strconv.Itoa(i) + "]"
+	case key.Kind() == reflect.String:
+		return "[" + strconv.Quote(key.String()) + "]"
+	}
+	return fmt.Sprintf("[%v]", key)
+}
+
+// rootType returns type of the top level value x.
+func rootType(x interface{}) reflect.Type {
+	t := reflect.TypeOf(x)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
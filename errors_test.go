@@ -0,0 +1,110 @@
+package binary
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type testOrderItem struct {
+	Name  string
+	Price float64
+}
+
+type testOrder struct {
+	ID    uint32
+	Items []testOrderItem
+	Attrs map[string]*testOrderItem
+	Any   interface{}
+}
+
+func TestDecodeError(t *testing.T) {
+	data := testOrder{
+		ID:    1,
+		Items: []testOrderItem{{"a", 1}, {"b", 2}},
+		Attrs: map[string]*testOrderItem{"x": {"c", 3}},
+		Any:   testEventCreated{ID: 5, Name: "any"},
+	}
+	b, err := Encode(&data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemsOffset := int64(Sizeof(data.ID))
+	price1 := itemsOffset + int64(SizeofUvarint(2)+Sizeof(data.Items[0])+Sizeof(data.Items[1].Name))
+	attrsOffset := itemsOffset + int64(Sizeof(data.Items))
+	anyOffset := attrsOffset + int64(Sizeof(data.Attrs))
+	nameOffset := anyOffset + int64(Sizeof(uint8(0))+Sizeof("binary.testEventCreated")+Sizeof(uint32(0)))
+
+	cases := []struct {
+		n      int64
+		path   string
+		typ    reflect.Type
+		offset int64
+	}{
+		{price1 + 7, "testOrder.Items[1].Price", reflect.TypeOf(float64(0)), price1},
+		{attrsOffset + 4, `testOrder.Attrs["x"].Name`, reflect.TypeOf(""), attrsOffset + 4},
+		{nameOffset + 1, "testOrder.Any.(binary.testEventCreated).Name", reflect.TypeOf(""), nameOffset},
+		{itemsOffset + 3, "testOrder.Items", reflect.TypeOf(data.Items), itemsOffset}, //length exceeds input
+	}
+	for _, c := range cases {
+		var r testOrder
+		err := Decode(b[:c.n], &r)
+		var e *DecodeError
+		if !errors.As(err, &e) {
+			t.Errorf("Decode(%d): have err %#v, want *DecodeError", c.n, err)
+			continue
+		}
+		if e.Path != c.path || e.Type != c.typ || e.Offset != c.offset {
+			t.Errorf("Decode(%d): have %s %s %d, want %s %s %d", c.n, e.Path, e.Type, e.Offset, c.path, c.typ, c.offset)
+		}
+		var te *TruncatedError
+		if !errors.As(err, &te) || !errors.Is(err, io.ErrUnexpectedEOF) || te.Offset+int64(te.Have) != c.n {
+			t.Errorf("Decode(%d): have err %v, want *TruncatedError", c.n, err)
+		}
+	}
+
+	var r testOrder
+	overflow := []byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if err := Decode(overflow, &r); !errors.As(err, new(*DecodeError)) {
+		t.Errorf("Decode: have err %v, want *DecodeError", err)
+	}
+}
+
+type testBadOrder struct {
+	A []testBadItem
+}
+
+type testBadItem struct {
+	B uint8
+	C map[string]uintptr
+}
+
+func TestEncodeError(t *testing.T) {
+	type unregistered struct{}
+	data := testOrder{
+		ID:    1,
+		Items: []testOrderItem{{"a", 1}},
+		Any:   unregistered{},
+	}
+	_, err := Encode(&data, nil)
+	var e *EncodeError
+	if !errors.As(err, &e) || e.Path != "testOrder.Any.(binary.unregistered)" || e.Type != reflect.TypeOf(data.Any) {
+		t.Errorf("Encode: have err %v, want *EncodeError of testOrder.Any", err)
+	}
+
+	bad := testBadOrder{A: make([]testBadItem, 3)}
+	var ue *UnsupportedTypeError
+	_, err = Encode(&bad, nil)
+	if !errors.As(err, &e) || !errors.As(err, &ue) || e.Path != "testBadOrder.A" || e.Offset != 0 {
+		t.Errorf("Encode: have err %v, want *UnsupportedTypeError of testBadOrder.A", err)
+	}
+	if err := Decode(make([]byte, 10), &bad); !errors.As(err, &ue) {
+		t.Errorf("Decode: have err %v, want *UnsupportedTypeError", err)
+	}
+
+	//top level unsupported type is returned directly
+	if _, err := Encode(uintptr(1), nil); !errors.As(err, &ue) || errors.As(err, &e) {
+		t.Errorf("Encode: have err %#v, want *UnsupportedTypeError", err)
+	}
+}
@@ -105,11 +105,19 @@ func Sizeof(data interface{}) int {
 // The error is EOF only if no bytes were read.
 // If an EOF happens after reading some but not all the bytes,
 // Read returns ErrUnexpectedEOF.
+//
+// The encoding is not the same as encoding/binary, see ReadStdCompatible.
 func Read(r io.Reader, endian Endian, data interface{}) error {
 	var decoder Decoder
 	decoder.Init(nil, endian)
 	decoder.reader = r
-	return decoder.Value(data)
+	if err := decoder.Value(data); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) { //the same as encoding/binary
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
 }
 
 // Write writes the binary representation of data into w.
@@ -120,29 +128,31 @@ func Read(r io.Reader, endian Endian, data interface{}) error {
 // and read from successive fields of the data.
 // When writing structs, zero values are written for fields
 // with blank (_) field names.
+//
+// The encoding is not the same as encoding/binary, see WriteStdCompatible.
 func Write(w io.Writer, endian Endian, data interface{}) error {
 	size := Sizeof(data)
 	if size < 0 {
 		return errors.New("binary.Write: invalid type " + reflect.TypeOf(data).String())
 	}
-	var b [16]byte
-	var bs []byte
-	if size > len(b) {
-		bs = make([]byte, size)
-	} else {
-		bs = b[:size]
-	}
+	return write(w, endian, data, size, false)
+}
 
-	var encoder Encoder
-	encoder.buff = bs
+// write encode data of size bytes to w.
+func write(w io.Writer, endian Endian, data interface{}, size int, stdCompatible bool) error {
+	if size > defaultStreamBufferSize { //encode large data as stream, to avoid holding all of it in memory
+		size = defaultStreamBufferSize
+	}
+	encoder := AcquireEncoder(size)
+	defer ReleaseEncoder(encoder)
 	encoder.setEndian(endian)
-	encoder.pos = 0
+	encoder.writer = w
+	encoder.stdCompatible = stdCompatible
 
-	err := encoder.Value(data)
-	assert(err == nil, err) //Value will never return error, because Sizeof(data) < 0 has blocked the error data
-
-	_, err = w.Write(encoder.Buffer())
-	return err
+	if err := encoder.Value(data); err != nil {
+		return err
+	}
+	return encoder.Flush()
 }
 
 // BinarySizer is an interface to define go data Size method.
@@ -170,18 +180,42 @@ type BinarySerializer interface {
 
 // Encode marshal go data to byte array.
 // nil buffer is aviable, it will create new buffer if necessary.
+// The buffer grows automatically while encoding, so it is not necessary
+// to call Sizeof before Encode.
+// If error, it returns the bytes that have been encoded before the error.
 func Encode(data interface{}, buffer []byte) ([]byte, error) {
-	buff, err := MakeEncodeBuffer(data, buffer)
-	if err != nil {
-		return nil, err
-	}
+	encoder := NewEncoderBuffer(buffer[:cap(buffer)])
+	encoder.autoGrow = true
+
+	err := encoder.Value(data)
+	return encoder.Buffer(), err
+}
 
-	encoder := NewEncoderBuffer(buff)
+// EncodeCanonical marshal go data to byte array like Encode,
+// but map entries are encoded in canonical order.
+// See Encoder.SetCanonical for details.
+func EncodeCanonical(data interface{}, buffer []byte) ([]byte, error) {
+	encoder := NewEncoderBuffer(buffer[:cap(buffer)])
+	encoder.autoGrow = true
+	encoder.canonical = true
 
-	err = encoder.Value(data)
+	err := encoder.Value(data)
 	return encoder.Buffer(), err
 }
 
+// AppendEncode marshal go data and append the result to dst.
+// It returns the extended buffer, and dst is returned unchanged if error.
+func AppendEncode(dst []byte, data interface{}) ([]byte, error) {
+	encoder := NewEncoderBuffer(dst[:cap(dst)])
+	encoder.autoGrow = true
+	encoder.pos = len(dst)
+
+	if err := encoder.Value(data); err != nil {
+		return dst, err
+	}
+	return encoder.Buffer(), nil
+}
+
 // Decode unmarshal go data from byte array.
 // data must be interface of pointer for modify.
 // It will make new pointer or slice/map for nil-field of data.
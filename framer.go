@@ -0,0 +1,253 @@
+// length-prefixed frames of messages over a stream, eg: net.Conn.
+
+package binary
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A frame is the length of payload, the payload and an optional checksum:
+//
+//	length    uvarint by default, or uint8/uint16/uint32 in Endian of the Framer,
+//	          see Framer.SetLengthPrefix
+//	payload   bytes of an encoded message
+//	checksum  CRC-32C (Castagnoli) of payload as uint32 in Endian of the Framer,
+//	          only if it is enabled by Framer.SetChecksum
+//
+// Both sides of a stream must use the same options.
+
+// ErrFrameChecksum is returned by Framer if checksum of a frame does not match it's payload.
+var ErrFrameChecksum = errors.New("binary.Framer: frame checksum mismatch")
+
+const (
+	maxFrameHeader = 10    //max bytes of frame length, that is a uvarint
+	frameReadChunk = 65536 //payload is read in chunks of this size, so that memory grows only as bytes arrive
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Framer reads and writes length-prefixed frames of messages on a stream.
+// Reads and writes can run in two goroutines at the same time, as net.Conn,
+// but concurrent reads or concurrent writes are not safe.
+type Framer struct {
+	reader   *bufio.Reader
+	writer   io.Writer
+	endian   Endian
+	prefix   int    //bits of fixed-width length, 0 for uvarint
+	checksum bool   //if frames have CRC-32C trailer
+	limits   Limits //Limits of Decoder, MaxBytes limits the payload size
+
+	encoder Encoder //for WriteFrame, it's buffer is reused
+	decoder Decoder //returned by ReadFrame
+	buff    []byte  //payload of last frame read
+}
+
+// NewFramer make a new Framer that reads and writes frames on rw with endian.
+// It reads from rw by a buffer, so it may read more bytes from rw than the frames need.
+func NewFramer(rw io.ReadWriter, endian Endian) *Framer {
+	f := &Framer{
+		reader: bufio.NewReader(rw),
+		writer: rw,
+		endian: endian,
+		limits: DefaultLimits,
+	}
+	f.encoder.Init(minGrowSize, endian)
+	f.encoder.autoGrow = true
+	return f
+}
+
+// SetLengthPrefix set the encoding of frame length.
+// bits is 0 for uvarint, the default, or 8, 16, 32 for fixed-width unsigned integer.
+func (f *Framer) SetLengthPrefix(bits int) error {
+	switch bits {
+	case 0, 8, 16, 32:
+		f.prefix = bits
+		return nil
+	}
+	return fmt.Errorf("binary.Framer: invalid length prefix of %d bits", bits)
+}
+
+// SetChecksum enable/disable the CRC-32C trailer of frames.
+func (f *Framer) SetChecksum(enable bool) {
+	f.checksum = enable
+}
+
+// SetLimits set Limits of decoding frames.
+// MaxBytes limits the payload size of frames, larger frames fail before
+// their payload is read, and the stream is broken after that.
+func (f *Framer) SetLimits(limits Limits) {
+	f.limits = limits
+}
+
+// WriteFrame encode data as a frame and write it.
+func (f *Framer) WriteFrame(data interface{}) error {
+	encoder := &f.encoder
+	encoder.Reset()
+	encoder.reserve(maxFrameHeader) //length is put before payload after encoding
+	if err := encoder.Value(data); err != nil {
+		return err
+	}
+	return f.writeFrame()
+}
+
+// WritePayload write bytes of an encoded message as a frame.
+func (f *Framer) WritePayload(payload []byte) error {
+	encoder := &f.encoder
+	encoder.Reset()
+	encoder.reserve(maxFrameHeader)
+	copy(encoder.reserve(len(payload)), payload)
+	return f.writeFrame()
+}
+
+// writeFrame write the payload in encoder buffer after maxFrameHeader bytes as a frame.
+func (f *Framer) writeFrame() error {
+	encoder := &f.encoder
+	b := encoder.Buffer()
+	size := uint64(len(b) - maxFrameHeader)
+	if f.prefix > 0 && size > 1<<uint(f.prefix)-1 {
+		return fmt.Errorf("binary.Framer: frame size %d exceeds %d bits length", size, f.prefix)
+	}
+	if f.checksum {
+		f.endian.PutUint32(encoder.reserve(4), crc32.Checksum(b[maxFrameHeader:], castagnoliTable))
+		b = encoder.Buffer()
+	}
+
+	var h [maxFrameHeader]byte
+	n := f.prefix / 8
+	switch f.prefix {
+	case 0:
+		n = PutUvarint(h[:], size)
+	case 8:
+		h[0] = uint8(size)
+	case 16:
+		f.endian.PutUint16(h[:], uint16(size))
+	case 32:
+		f.endian.PutUint32(h[:], uint32(size))
+	}
+	start := maxFrameHeader - n
+	copy(b[start:], h[:n])
+	return writeFull(f.writer, b[start:])
+}
+
+// writeFull write all of b to w, even if w writes part of it without error.
+func writeFull(w io.Writer, b []byte) error {
+	for len(b) > 0 {
+		n, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// ReadFrame read the next frame, and returns a Decoder of it's payload with Limits of the Framer.
+// The Decoder and values decoded in zero-copy mode are valid until the next ReadFrame.
+// It returns io.EOF if the stream ends before a frame, and io.ErrUnexpectedEOF
+// if it ends in the middle of a frame.
+func (f *Framer) ReadFrame() (*Decoder, error) {
+	payload, err := f.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	f.decoder = Decoder{}
+	f.decoder.Init(payload, f.endian)
+	f.decoder.limits = f.limits
+	return &f.decoder, nil
+}
+
+// ReadValue read the next frame and decode it to data.
+// It fails if the payload is not consumed by data entirely.
+func (f *Framer) ReadValue(data interface{}) error {
+	decoder, err := f.ReadFrame()
+	if err != nil {
+		return err
+	}
+	if err := decoder.Value(data); err != nil {
+		return err
+	}
+	if n := len(decoder.buff) - decoder.pos; n > 0 {
+		return fmt.Errorf("binary.Framer: %d bytes of frame are not decoded", n)
+	}
+	return nil
+}
+
+// readFrame read the next frame and returns it's payload.
+func (f *Framer) readFrame() ([]byte, error) {
+	size, err := f.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if max := f.limits.MaxBytes; max > 0 && size > uint64(max) {
+		return nil, &LimitError{Limit: "MaxBytes", Value: size, Max: uint64(max)}
+	}
+	n := size
+	if f.checksum {
+		n += 4
+	}
+	if n > uint64(maxInt) {
+		return nil, fmt.Errorf("binary.Framer: frame size %d overflows int", size)
+	}
+
+	b := f.buff[:0]
+	for len(b) < int(n) { //grow by chunks, a large length with few bytes does not allocate much
+		m := int(n) - len(b)
+		if m > frameReadChunk {
+			m = frameReadChunk
+		}
+		if cap(b)-len(b) < m {
+			buff := make([]byte, len(b), 2*cap(b)+m)
+			copy(buff, b)
+			b = buff
+		}
+		if _, err := io.ReadFull(f.reader, b[len(b):len(b)+m]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		b = b[:len(b)+m]
+	}
+	f.buff = b
+
+	payload := b[:size]
+	if f.checksum && f.endian.Uint32(b[size:]) != crc32.Checksum(payload, castagnoliTable) {
+		return nil, ErrFrameChecksum
+	}
+	return payload, nil
+}
+
+// readLength read length of the next frame, it returns io.EOF if there is no more frames.
+func (f *Framer) readLength() (uint64, error) {
+	if f.prefix == 0 {
+		if _, err := f.reader.Peek(1); err != nil {
+			return 0, err
+		}
+		size, err := ReadUvarint(f.reader)
+		return size, unexpectedEOF(err)
+	}
+	var h [4]byte
+	b := h[:f.prefix/8]
+	if _, err := io.ReadFull(f.reader, b); err != nil {
+		return 0, err //io.EOF if no bytes are read
+	}
+	switch f.prefix {
+	case 8:
+		return uint64(b[0]), nil
+	case 16:
+		return uint64(f.endian.Uint16(b)), nil
+	}
+	return uint64(f.endian.Uint32(b)), nil
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF if err is io.EOF in the middle of a frame.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,194 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type frameRequest struct {
+	ID   uint32
+	Op   string
+	Args []int32
+}
+
+type frameResponse struct {
+	ID  uint32
+	Sum int64
+	Ok  bool
+}
+
+// serveFrames answers requests from conn until it is closed.
+func serveFrames(conn net.Conn, prefix int, checksum bool) error {
+	defer conn.Close()
+	f := NewFramer(conn, BigEndian)
+	f.SetLengthPrefix(prefix)
+	f.SetChecksum(checksum)
+	for {
+		var req frameRequest
+		if err := f.ReadValue(&req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		resp := frameResponse{ID: req.ID, Ok: req.Op == "sum"}
+		for _, x := range req.Args {
+			resp.Sum += int64(x)
+		}
+		if err := f.WriteFrame(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+func TestFramer(t *testing.T) {
+	for _, prefix := range []int{0, 8, 16, 32} {
+		for _, checksum := range []bool{false, true} {
+			client, server := net.Pipe()
+			done := make(chan error, 1)
+			go func() {
+				done <- serveFrames(server, prefix, checksum)
+			}()
+
+			f := NewFramer(client, BigEndian)
+			if err := f.SetLengthPrefix(prefix); err != nil {
+				t.Fatal(err)
+			}
+			f.SetChecksum(checksum)
+			for i := 0; i < 10; i++ {
+				req := frameRequest{ID: uint32(i), Op: "sum", Args: make([]int32, i)}
+				want := frameResponse{ID: uint32(i), Ok: true}
+				for j := range req.Args {
+					req.Args[j] = int32(j * 100)
+					want.Sum += int64(j * 100)
+				}
+				if err := f.WriteFrame(&req); err != nil {
+					t.Fatalf("prefix=%d checksum=%v: WriteFrame: %v", prefix, checksum, err)
+				}
+				var resp frameResponse
+				if err := f.ReadValue(&resp); err != nil || resp != want {
+					t.Fatalf("prefix=%d checksum=%v: ReadValue: have %+v, %v, want %+v", prefix, checksum, resp, err, want)
+				}
+			}
+			client.Close()
+			if err := <-done; err != nil {
+				t.Errorf("prefix=%d checksum=%v: server: %v", prefix, checksum, err)
+			}
+		}
+	}
+}
+
+// shortWriter writes at most 3 bytes each time.
+type shortWriter struct {
+	w io.Writer
+}
+
+func (w shortWriter) Write(b []byte) (int, error) {
+	if len(b) > 3 {
+		b = b[:3]
+	}
+	return w.w.Write(b)
+}
+
+type frameStream struct {
+	io.Reader
+	io.Writer
+}
+
+func TestFramerPartial(t *testing.T) {
+	var w bytes.Buffer
+	f := NewFramer(frameStream{iotest.OneByteReader(&w), shortWriter{&w}}, LittleEndian)
+	f.SetChecksum(true)
+	large := strings.Repeat("0123456789", 20000) //larger than a read chunk
+	values := []string{"", "a", large, "end"}
+	for _, s := range values {
+		if err := f.WriteFrame(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.WritePayload([]byte{3, 'r', 'a', 'w'}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range append(values, "raw") {
+		var x string
+		if err := f.ReadValue(&x); err != nil || x != s {
+			t.Fatalf("ReadValue: have %d bytes, %v, want %d bytes", len(x), err, len(s))
+		}
+	}
+	if d, err := f.ReadFrame(); err != io.EOF {
+		t.Errorf("ReadFrame at end: have %v, %v, want io.EOF", d, err)
+	}
+}
+
+func TestFramerError(t *testing.T) {
+	var w bytes.Buffer
+	f := NewFramer(&w, LittleEndian)
+	if err := f.SetLengthPrefix(12); err == nil {
+		t.Errorf("SetLengthPrefix(12): have err == nil")
+	}
+
+	f.SetLengthPrefix(8)
+	if err := f.WritePayload(make([]byte, 256)); err == nil || w.Len() != 0 {
+		t.Errorf("WritePayload too large: have %v, %d bytes written", err, w.Len())
+	}
+	f.SetLengthPrefix(0)
+
+	f.WriteFrame("0123456789")
+	f.SetLimits(Limits{MaxBytes: 4})
+	var e *LimitError
+	if _, err := f.ReadFrame(); !errors.As(err, &e) || e.Limit != "MaxBytes" || e.Value != 11 {
+		t.Errorf("ReadFrame over MaxBytes: have %v", err)
+	}
+
+	w.Reset()
+	f = NewFramer(&w, LittleEndian)
+	f.SetLimits(Limits{MaxStringLen: 4}) //Limits of Decoder
+	f.WriteFrame("0123456789")
+	var s string
+	if err := f.ReadValue(&s); !errors.As(err, &e) || e.Limit != "MaxStringLen" {
+		t.Errorf("ReadValue over MaxStringLen: have %v", err)
+	}
+
+	f.WriteFrame([]uint8{1, 2})
+	var x uint8
+	if err := f.ReadValue(&x); err == nil {
+		t.Errorf("ReadValue with bytes left: have err == nil")
+	}
+
+	f.SetChecksum(true)
+	f.WriteFrame("abc")
+	w.Bytes()[2] ^= 1
+	if _, err := f.ReadFrame(); err != ErrFrameChecksum {
+		t.Errorf("ReadFrame with bad checksum: have %v, want %v", err, ErrFrameChecksum)
+	}
+
+	for _, b := range [][]byte{{0x80}, {3, 1, 2}} {
+		w.Reset()
+		w.Write(b)
+		f = NewFramer(&w, LittleEndian)
+		if d, err := f.ReadFrame(); err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadFrame(%v): have %v, %v, want io.ErrUnexpectedEOF", b, d, err)
+		}
+	}
+
+	cases := []struct {
+		prefix int
+		b      []byte
+	}{
+		{16, []byte{0x10, 0x00, 1, 2}}, //payload is truncated
+		{32, []byte{0x10, 0x00}},       //length is truncated
+	}
+	for _, c := range cases {
+		w.Reset()
+		w.Write(c.b)
+		f = NewFramer(&w, LittleEndian)
+		f.SetLengthPrefix(c.prefix)
+		if d, err := f.ReadFrame(); err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadFrame(prefix=%d, %v): have %v, %v, want io.ErrUnexpectedEOF", c.prefix, c.b, d, err)
+		}
+	}
+}
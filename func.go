@@ -191,16 +191,25 @@ func bitsOfUnfixedArray(v reflect.Value, packed bool) int {
 		return -1
 	}
 
+	kind := customOf(v.Type().Elem())
 	arrayLen := v.Len()
 	sum := SizeofUvarint(uint64(arrayLen)) * 8 //array size bytes num
 	for i, n := 0, arrayLen; i < n; i++ {
-		s := bitsOfValue(v.Index(i), false, packed)
+		s := bitsOfElem(v.Index(i), kind, packed)
 		//assert(s >= 0, v.Type().String()) //element size must not error
 		sum += s
 	}
 	return sum
 }
 
+// bitsOfElem returns bits of nested value v that may be custom.
+func bitsOfElem(v reflect.Value, kind customKind, packed bool) int {
+	if kind != customNone {
+		return bitsOfCustom(v, kind)
+	}
+	return bitsOfValue(v, false, packed)
+}
+
 // sizeof returns the size >= 0 of variables for the given type or -1 if the type is not acceptable.
 func bitsOfValue(v reflect.Value, topLevel bool, packed bool) (r int) {
 	//	defer func() {
@@ -221,6 +230,17 @@ func bitsOfValue(v reflect.Value, topLevel bool, packed bool) (r int) {
 
 	v = reflect.Indirect(v) //redrect pointer to it's value
 	t := v.Type()
+	if p := planOf(t, packed); p != nil { //compiled plan, see plan.go
+		if ptr := planPtr(v, p); ptr != nil {
+			if s := p.bitsOf(ptr); s >= 0 {
+				return s + bits
+			}
+			return -1
+		}
+	}
+	if std := stdTypeOf(t); std != nil { //standard library types
+		return std.bits(v) + bits
+	}
 	if s := fixedTypeSize(t); s > 0 { //fixed size
 		if packedType := packedIntsType(t); packedType > 0 && packed {
 			switch packedType {
@@ -243,6 +263,9 @@ func bitsOfValue(v reflect.Value, topLevel bool, packed bool) (r int) {
 	case reflect.Slice, reflect.Array:
 		arrayLen := v.Len()
 		elemtype := t.Elem()
+		if customOf(elemtype) != customNone {
+			return bitsOfUnfixedArray(v, packed) + bits
+		}
 		if s := fixedTypeSize(elemtype); s > 0 {
 			if packedIntsType(elemtype) > 0 && packed {
 				return bitsOfUnfixedArray(v, packed) + bits
@@ -258,21 +281,21 @@ func bitsOfValue(v reflect.Value, topLevel bool, packed bool) (r int) {
 	case reflect.Map:
 		mapLen := v.Len()
 		sum := SizeofUvarint(uint64(mapLen))*8 + bits //array size
-		keys := v.MapKeys()
 
 		if !validUserType(t.Key()) ||
 			!validUserType(t.Elem()) { //check if map key and value type valid
 			return -1
 		}
 
-		for i := 0; i < mapLen; i++ {
-			key := keys[i]
-			sizeKey := bitsOfValue(key, false, packed)
+		keyKind, valueKind := customOf(t.Key()), customOf(t.Elem())
+		for it := v.MapRange(); it.Next(); { //MapIndex can not find NaN keys
+			key := it.Key()
+			sizeKey := bitsOfElem(key, keyKind, packed)
 			//assert(sizeKey >= 0, key.Type().Kind().String()) //key size must not error
 
 			sum += sizeKey
-			value := v.MapIndex(key)
-			sizeValue := bitsOfValue(value, false, packed)
+			value := it.Value()
+			sizeValue := bitsOfElem(value, valueKind, packed)
 			//assert(sizeValue >= 0, value.Type().Kind().String()) //key size must not error
 
 			sum += sizeValue
@@ -282,6 +305,11 @@ func bitsOfValue(v reflect.Value, topLevel bool, packed bool) (r int) {
 	case reflect.Struct:
 		return queryStruct(v.Type()).bitsOfValue(v) + bits
 
+	case reflect.Interface:
+		if s := bitsOfInterface(v); s >= 0 {
+			return s + bits
+		}
+
 	case reflect.String:
 		return sizeofString(v.Len())*8 + bits //string length and data
 	}
@@ -297,6 +325,9 @@ func sizeofNilPointer(t reflect.Type) int {
 	if tt.Kind() == reflect.Ptr {
 		tt = t.Elem()
 	}
+	if std := stdTypeOf(tt); std != nil { //standard library types
+		return (std.minBits + 7) / 8
+	}
 	if s := fixedTypeSize(tt); s > 0 { //fix size
 		return s
 	}
@@ -329,10 +360,20 @@ func sizeofNilPointer(t reflect.Type) int {
 		if size > 0 { //verify element type valid
 			return sizeofFixArray(tt.Len(), size)
 		}
+		if customOf(elemtype) != customNone { //length of custom bytes
+			return sizeofFixArray(tt.Len(), 1)
+		}
 	case reflect.Struct:
-		return queryStruct(tt).sizeofNilPointer(tt)
+		if s := _structInfoMgr.lookup(tt).sizeofNilPointer(tt); s >= 0 { //do not auto regist here, query calls validUserType
+			return s
+		}
+	case reflect.Interface: //nil interface
+		return SizeofUvarint(ifaceNil)
 	}
 
+	if customOf(t) != customNone { //custom type with unsupported fields, length of custom bytes
+		return 1
+	}
 	return -1
 }
 
@@ -359,7 +400,12 @@ func fixedTypeSize(t reflect.Type) int {
 		return 2
 	case reflect.Int32, reflect.Uint32, reflect.Float32:
 		return 4
-	case reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Complex64:
+	case reflect.Int64:
+		if t == tDuration { //varint, see stdTypes
+			return -1
+		}
+		return 8
+	case reflect.Uint64, reflect.Float64, reflect.Complex64:
 		return 8
 	case reflect.Complex128:
 		return 16
@@ -380,7 +426,7 @@ func newPtr(v reflect.Value, decoder *Decoder, topLevel bool) bool {
 		case reflect.Int, reflect.Uint, reflect.Bool, reflect.Int8, reflect.Uint8, reflect.Int16,
 			reflect.Uint16, reflect.Int32, reflect.Uint32, reflect.Int64,
 			reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Complex64,
-			reflect.Complex128, reflect.String:
+			reflect.Complex128, reflect.String, reflect.Interface:
 			isNotNilPointer := false
 			if !topLevel {
 				isNotNilPointer = decoder.Bool()
@@ -400,7 +446,7 @@ func newPtr(v reflect.Value, decoder *Decoder, topLevel bool) bool {
 // This function will make the encode/decode of struct slow down.
 // It is recommended to use RegStruct to improve this case.
 func validField(f reflect.StructField) bool {
-	if isExported(f.Name) && f.Tag.Get("binary") != "ignore" {
+	if tag, _ := parseTag(f.Tag.Get("binary")); isExported(f.Name) && !tag.ignore {
 		return true
 	}
 	return false
@@ -0,0 +1,381 @@
+// length of strings, slices and arrays of struct fields with tags len, lenprefix and lenfield.
+
+package binary
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// By default, length of string, slice and array is encoded as uvarint before it's elements.
+// Struct fields can change it to describe network protocol headers:
+//
+//	len=N           N elements without length prefix, shorter value is padded with zero
+//	                elements, longer value fails. Trailing zero bytes of string are
+//	                trimmed when decoding, slice is decoded with N elements.
+//	                N must be the length of array.
+//	lenprefix=u8    length is encoded as uint8
+//	lenprefix=u16   length is encoded as uint16 in Endian of the coder
+//	lenprefix=u32   length is encoded as uint32 in Endian of the coder
+//	lenfield=Name   length is the value of an earlier integer field Name, they must
+//	                be equal when encoding
+//
+// Length tags work for both registed and unregisted structs,
+// but not for versioned structs and SchemaOf.
+
+// lengthKind is the encoding of length of a string, slice or array field.
+type lengthKind uint8
+
+const (
+	lengthUvarint lengthKind = iota //uvarint prefix, the default
+	lengthFixed                     //len=N
+	lengthU8                        //lenprefix=u8
+	lengthU16                       //lenprefix=u16
+	lengthU32                       //lenprefix=u32
+	lengthField                     //lenfield=Name
+)
+
+// fieldLength is the length encoding of a struct field.
+type fieldLength struct {
+	kind  lengthKind
+	n     int //N of len=N
+	field int //index of the field of lenfield
+}
+
+// parseLength checks length tag of field i and returns it's fieldLength.
+func (info *structInfo) parseLength(t reflect.Type, i int, tag fieldTag) (fieldLength, error) {
+	l := fieldLength{kind: tag.length, n: tag.lenN}
+	f := t.Field(i)
+	switch f.Type.Kind() {
+	case reflect.String, reflect.Slice:
+	case reflect.Array:
+		if l.kind == lengthFixed && l.n != f.Type.Len() {
+			return l, fmt.Errorf("len=%d of array %s does not match it's length", l.n, f.Type.String())
+		}
+	default:
+		return l, fmt.Errorf("length tag is valid for string, slice and array only, but got %s", f.Type.String())
+	}
+	if checkCustom(f.Type) != customNone || stdTypeOf(f.Type) != nil { //customOf locks the registry
+		return l, fmt.Errorf("length tag is not valid for type %s that encodes itself", f.Type.String())
+	}
+	if l.kind == lengthField {
+		l.field = -1
+		for j, g := range info.fields[:i] {
+			if g.field.Name == tag.lenField && !g.ignore && g.length.kind == lengthUvarint && g.bits.n == 0 {
+				switch g.field.Type.Kind() {
+				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+					if stdTypeOf(g.field.Type) == nil && g.custom == customNone {
+						l.field = j
+					}
+				}
+			}
+		}
+		if l.field < 0 {
+			return l, fmt.Errorf("lenfield %s must be an earlier encoded integer field", tag.lenField)
+		}
+		info.fields[l.field].counter = true
+	}
+	return l, nil
+}
+
+// max returns the max length that can be encoded.
+func (l *fieldLength) max() uint64 {
+	switch l.kind {
+	case lengthFixed:
+		return uint64(l.n)
+	case lengthU8:
+		return math.MaxUint8
+	case lengthU16:
+		return math.MaxUint16
+	case lengthU32:
+		return math.MaxUint32
+	}
+	return math.MaxUint64
+}
+
+// prefixBits returns number of bits of length prefix.
+func (l *fieldLength) prefixBits() int {
+	switch l.kind {
+	case lengthU8:
+		return 8
+	case lengthU16:
+		return 16
+	case lengthU32:
+		return 32
+	}
+	return 0
+}
+
+// minSize returns the min number of bytes to encode a value of t, see sizeofNilPointer.
+func (l *fieldLength) minSize(t reflect.Type) int {
+	if l.kind != lengthFixed {
+		return l.prefixBits() / 8
+	}
+	switch {
+	case t.Kind() == reflect.String:
+		return l.n
+	case t.Elem().Kind() == reflect.Bool:
+		return (l.n + 7) / 8
+	}
+	if s := sizeofNilPointer(t.Elem()); s > 0 {
+		return l.n * s
+	}
+	return 0
+}
+
+// minBits returns the min number of bits to encode a value of t, see minBitsOf.
+func (l *fieldLength) minBits(t reflect.Type, packed bool) int {
+	if l.kind != lengthFixed {
+		return l.prefixBits()
+	}
+	switch {
+	case t.Kind() == reflect.String:
+		return l.n * 8
+	case t.Elem().Kind() == reflect.Bool:
+		return (l.n + 7) / 8 * 8
+	}
+	return l.n * minBitsOf(t.Elem(), packed)
+}
+
+// lengthOf returns value of integer field v that is referenced by lenfield.
+func lengthOf(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if x := v.Int(); x >= 0 {
+			return uint64(x)
+		}
+		return math.MaxUint64
+	}
+	return v.Uint()
+}
+
+// bitsOfLength returns number of bits to encode field i of struct v, or -1 if it fails.
+func (info *structInfo) bitsOfLength(v reflect.Value, i int) int {
+	f := info.fields[i]
+	l := &f.length
+	fv := v.Field(i)
+	n := fv.Len()
+	if l.kind == lengthField && lengthOf(v.Field(l.field)) != uint64(n) || uint64(n) > l.max() {
+		return -1
+	}
+	count := n
+	if l.kind == lengthFixed {
+		count = l.n
+	}
+	bits := l.prefixBits()
+	switch t := fv.Type(); {
+	case t.Kind() == reflect.String:
+		return bits + count*8
+	case t.Elem().Kind() == reflect.Bool:
+		return bits + (count+7)/8*8
+	}
+	kind := customOf(fv.Type().Elem())
+	for j := 0; j < n; j++ {
+		s := bitsOfElem(fv.Index(j), kind, f.packed)
+		if s < 0 {
+			return -1
+		}
+		bits += s
+	}
+	if count > n {
+		s := bitsOfElem(reflect.Zero(fv.Type().Elem()), kind, f.packed)
+		if s < 0 {
+			return -1
+		}
+		bits += (count - n) * s
+	}
+	return bits
+}
+
+// lengthValue encode field i of struct v with it's length tag.
+func (encoder *Encoder) lengthValue(v reflect.Value, i int, f *fieldInfo) {
+	l := &f.length
+	fv := v.Field(i)
+	n := fv.Len()
+	if uint64(n) > l.max() {
+		panic(fmt.Errorf("binary.Encoder.Value: length %d exceeds %d", n, l.max()))
+	}
+	count := n
+	switch l.kind {
+	case lengthFixed:
+		count = l.n
+	case lengthU8:
+		encoder.Uint8(uint8(n))
+	case lengthU16:
+		encoder.Uint16(uint16(n), false)
+	case lengthU32:
+		encoder.Uint32(uint32(n), false)
+	case lengthField:
+		if x := lengthOf(v.Field(l.field)); x != uint64(n) {
+			panic(fmt.Errorf("binary.Encoder.Value: length %d does not match lenfield %s=%d", n, v.Type().Field(l.field).Name, x))
+		}
+	}
+
+	t := fv.Type()
+	switch {
+	case t.Kind() == reflect.String:
+		encoder.writeString(fv.String())
+		encoder.zeros(count - n)
+		return
+	case t.Elem().Kind() == reflect.Bool: //bits in bytes of it's own
+		var b []byte
+		for j := 0; j < count; j++ {
+			if j%8 == 0 {
+				b = encoder.reserve(1)
+				b[0] = 0
+			}
+			if j < n && fv.Index(j).Bool() {
+				b[0] |= 1 << uint(j%8)
+			}
+		}
+		return
+	case t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice:
+		copy(encoder.reserve(n), fv.Bytes())
+		encoder.zeros(count - n)
+		return
+	}
+
+	j, offset := 0, encoder.offset()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, indexPath(j), t.Elem(), offset)
+		}
+	}()
+	kind := customOf(t.Elem())
+	zero := reflect.Zero(t.Elem())
+	for ; j < count; j++ {
+		offset = encoder.offset()
+		if j < n {
+			encoder.elem(fv.Index(j), kind, f.packed)
+		} else {
+			encoder.elem(zero, kind, f.packed)
+		}
+	}
+}
+
+// zeros encode n zero bytes.
+func (encoder *Encoder) zeros(n int) {
+	if n > 0 {
+		b := encoder.reserve(n)
+		for i := range b {
+			b[i] = 0
+		}
+	}
+}
+
+// taggedLen decode length of a value of t with length tag l.
+// field is the value of lenfield.
+func (decoder *Decoder) taggedLen(l *fieldLength, t reflect.Type, packed bool, field uint64) int {
+	var s uint64
+	switch l.kind {
+	case lengthFixed:
+		s = uint64(l.n)
+	case lengthU8:
+		s = uint64(decoder.Uint8())
+	case lengthU16:
+		s = uint64(decoder.Uint16(false))
+	case lengthU32:
+		s = uint64(decoder.Uint32(false))
+	case lengthField:
+		s = field
+	}
+	switch {
+	case t.Kind() == reflect.String:
+		return decoder.checkLength(s, "MaxStringLen", decoder.limits.MaxStringLen, 8)
+	case t.Elem().Kind() == reflect.Bool:
+		return decoder.checkLength(s, "MaxSliceLen", decoder.limits.MaxSliceLen, 1)
+	}
+	return decoder.checkLength(s, "MaxSliceLen", decoder.limits.MaxSliceLen, minBitsOf(t.Elem(), packed))
+}
+
+// lengthValue decode field i of struct v with it's length tag.
+func (decoder *Decoder) lengthValue(v reflect.Value, i int, f *fieldInfo) {
+	l := &f.length
+	fv := v.Field(i)
+	var field uint64
+	if l.kind == lengthField {
+		field = lengthOf(v.Field(l.field))
+	}
+	n := decoder.taggedLen(l, fv.Type(), f.packed, field)
+
+	t := fv.Type()
+	if t.Kind() == reflect.String {
+		b := decoder.reserve(n)
+		if l.kind == lengthFixed {
+			b = bytes.TrimRight(b, "\x00")
+		}
+		fv.SetString(decoder.makeString(b))
+		return
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		if b := decoder.reserve(n); n > 0 {
+			fv.SetBytes(decoder.makeBytes(b))
+		}
+		return
+	}
+
+	decoder.enter()
+	defer decoder.leave()
+	if n > 0 && t.Kind() == reflect.Slice { //make a new slice
+		fv.Set(reflect.MakeSlice(t, n, n))
+	}
+	switch {
+	case t.Elem().Kind() == reflect.Bool:
+		var b []byte
+		for j, m := 0, fv.Len(); j < n; j++ {
+			if j%8 == 0 {
+				b = decoder.reserve(1)
+			}
+			if j < m { //skip bits out of array
+				fv.Index(j).SetBool(b[0]&(1<<uint(j%8)) != 0)
+			}
+		}
+		return
+	}
+
+	j, offset := 0, decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, indexPath(j), t.Elem(), offset)
+		}
+	}()
+	if minBitsOf(t.Elem(), f.packed) == 0 { //nothing to decode for empty elements
+		n = 0
+	}
+	kind := customOf(t.Elem())
+	for m := fv.Len(); j < n; j++ {
+		offset = decoder.Consumed()
+		if j < m {
+			decoder.elem(fv.Index(j), kind, f.packed)
+		} else {
+			decoder.skipByType(t.Elem(), f.packed)
+		}
+	}
+}
+
+// skipLength skip a value of t with length tag l.
+// field is the value of lenfield.
+func (decoder *Decoder) skipLength(l *fieldLength, t reflect.Type, packed bool, field uint64) {
+	n := decoder.taggedLen(l, t, packed, field)
+	switch {
+	case t.Kind() == reflect.String:
+		decoder.Skip(n)
+		return
+	case t.Elem().Kind() == reflect.Bool:
+		decoder.Skip((n + 7) / 8)
+		return
+	}
+	decoder.enter()
+	defer decoder.leave()
+	et := t.Elem()
+	if s := fixedTypeSize(et); s > 0 && !(packed && packedIntsType(et) > 0) {
+		decoder.Skip(n * s)
+	} else if minBitsOf(et, packed) > 0 { //nothing to skip for empty elements
+		for j := 0; j < n; j++ {
+			decoder.skipByType(et, packed)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type lengthHeader struct {
+	Magic [4]byte `binary:"len=4"`
+	Name  string  `binary:"len=8"`
+	Flags []bool  `binary:"len=3"`
+	Count uint16
+	Skip  int      `binary:"ignore"`
+	Items []uint32 `binary:"lenfield=Count"`
+	Data  []byte   `binary:"lenprefix=u16"`
+	Tags  []string `binary:"lenprefix=u8"`
+	Ok    bool
+	Note  string `binary:"lenprefix=u32"`
+}
+
+func TestLength(t *testing.T) {
+	x := lengthHeader{
+		Magic: [4]byte{'A', 'B', 'C', 'D'},
+		Name:  "hi",
+		Flags: []bool{true, false, true},
+		Count: 2,
+		Items: []uint32{1, 2},
+		Data:  []byte("xy"),
+		Tags:  []string{"a"},
+		Ok:    true,
+		Note:  "n",
+	}
+	want := []byte{
+		0x41, 0x42, 0x43, 0x44, //Magic
+		0x68, 0x69, 0, 0, 0, 0, 0, 0, //Name
+		0x05,       //Flags
+		0x02, 0x00, //Count
+		0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, //Items
+		0x02, 0x00, 0x78, 0x79, //Data
+		0x01, 0x01, 0x61, //Tags
+		0x01,                         //Ok
+		0x01, 0x00, 0x00, 0x00, 0x6e, //Note
+	}
+	b, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Encode:\nhave %x\nwant %x", b, want)
+	}
+	if n := Sizeof(&x); n != len(want) {
+		t.Errorf("Sizeof: have %d, want %d", n, len(want))
+	}
+	var y lengthHeader
+	if err := Decode(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(y, x) {
+		t.Errorf("Decode:\nhave %+v\nwant %+v", y, x)
+	}
+
+	//prefixes in big endian
+	encoder := NewEncoderEndian(len(want), BigEndian)
+	if err := encoder.Value(&x); err != nil {
+		t.Fatal(err)
+	}
+	b = encoder.Buffer()
+	if !bytes.Equal(b[13:15], []byte{0, 2}) || !bytes.Equal(b[23:25], []byte{0, 2}) || !bytes.Equal(b[31:35], []byte{0, 0, 0, 1}) {
+		t.Errorf("Encode BigEndian: have %x", b)
+	}
+	y = lengthHeader{}
+	decoder := NewDecoderEndian(b, BigEndian)
+	if err := decoder.Value(&y); err != nil || !reflect.DeepEqual(y, x) {
+		t.Errorf("Decode BigEndian: have %+v, %v", y, err)
+	}
+
+	//skip elements out of array
+	b, err = Encode([]lengthHeader{x, x}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, 0x7f)
+	var a [1]lengthHeader
+	var end int8
+	decoder = NewDecoder(b)
+	if err := decoder.Value(&a); err != nil || !reflect.DeepEqual(a[0], x) {
+		t.Errorf("Decode array: have %+v, %v", a[0], err)
+	}
+	if err := decoder.Value(&end); err != nil || end != 0x7f {
+		t.Errorf("Decode after skipped elements: have %d, %v", end, err)
+	}
+}
+
+type lengthName string
+
+func TestLengthNamedType(t *testing.T) {
+	x := struct {
+		A lengthName `binary:"len=4"`
+		B []byte     `binary:"lenprefix=u8"`
+	}{A: "ab", B: []byte{1}}
+	want := []byte{0x61, 0x62, 0, 0, 0x01, 0x01}
+	if b, err := Encode(&x, nil); err != nil || !bytes.Equal(b, want) {
+		t.Errorf("Encode: have %x, %v, want %x", b, err, want)
+	}
+}
+
+func TestLengthSkip(t *testing.T) {
+	b, err := Encode([]lengthHeader{{Count: 6, Items: make([]uint32, 6)}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x [0]lengthHeader //skipped by counter Count
+	if err := Decode(b, &x); err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewDecoder(b)
+	decoder.SetLimits(Limits{MaxSliceLen: 5})
+	var limit *LimitError
+	if err := decoder.Value(&x); !errors.As(err, &limit) {
+		t.Errorf("skip Items over MaxSliceLen: have %v, want *LimitError", err)
+	}
+	for i := 1; i < len(b); i++ { //include truncated Count
+		var truncated *TruncatedError
+		if err := Decode(b[:i], &x); !errors.As(err, &truncated) {
+			t.Errorf("skip truncated at %d: have %v, want *TruncatedError", i, err)
+		}
+	}
+}
+
+func TestLengthError(t *testing.T) {
+	x := lengthHeader{Name: "too long name", Count: 1, Items: []uint32{1}}
+	if _, err := Encode(&x, nil); err == nil || !strings.Contains(err.Error(), "lengthHeader.Name") {
+		t.Errorf("Encode long Name: have %v, want error of Name", err)
+	}
+	if n := Sizeof(&x); n != -1 {
+		t.Errorf("Sizeof long Name: have %d, want -1", n)
+	}
+	x.Name = ""
+	x.Count = 2
+	if _, err := Encode(&x, nil); err == nil || !strings.Contains(err.Error(), "lenfield Count=2") {
+		t.Errorf("Encode mismatched Count: have %v", err)
+	}
+	x.Count = 1
+	x.Tags = make([]string, 256)
+	if _, err := Encode(&x, nil); err == nil || !strings.Contains(err.Error(), "lengthHeader.Tags") {
+		t.Errorf("Encode too many Tags: have %v", err)
+	}
+
+	//limits of prefix
+	x.Tags = nil
+	x.Data = make([]byte, 10)
+	b, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewDecoder(b)
+	decoder.SetLimits(Limits{MaxSliceLen: 5})
+	var limit *LimitError
+	if err := decoder.Value(&x); !errors.As(err, &limit) {
+		t.Errorf("Decode Data over MaxSliceLen: have %v, want *LimitError", err)
+	}
+	var truncated *TruncatedError
+	if err := Decode(b[:len(b)-1], &x); !errors.As(err, &truncated) {
+		t.Errorf("Decode truncated: have %v, want *TruncatedError", err)
+	}
+
+	var tagErr *TagError
+	for _, data := range []interface{}{
+		&struct {
+			A int `binary:"len=1"`
+		}{},
+		&struct {
+			A [2]byte `binary:"len=3"`
+		}{},
+		&struct {
+			A string `binary:"len=1,lenprefix=u8"`
+		}{},
+		&struct {
+			A string `binary:"lenprefix=u64"`
+		}{},
+		&struct {
+			A string `binary:"lenfield=N"`
+			N int
+		}{},
+		&struct {
+			N string
+			A string `binary:"lenfield=N"`
+		}{},
+		&struct {
+			N int    `binary:"ignore"`
+			A string `binary:"lenfield=N"`
+		}{},
+		&struct {
+			A string `binary:"id=1,lenprefix=u8"`
+		}{},
+	} {
+		if _, err := Encode(data, nil); !errors.As(err, &tagErr) {
+			t.Errorf("Encode %T: have %v, want *TagError", data, err)
+		}
+	}
+	if _, err := SchemaOf(reflect.TypeOf(x)); !errors.As(err, &tagErr) {
+		t.Errorf("SchemaOf: have %v, want *TagError", err)
+	}
+}
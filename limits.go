@@ -0,0 +1,175 @@
+// limit resources that decoding untrusted input may use.
+
+package binary
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Limits restrict the resources that a Decoder may use to decode untrusted input.
+// Zero value of a field means no limit.
+//
+// Regardless of Limits, Decoder never allocates a slice or string that is larger
+// than the input can hold, so a short hostile input can not exhaust memory.
+type Limits struct {
+	MaxBytes     int64 // max number of bytes that a single Value may consume
+	MaxSliceLen  int   // max length of slices and arrays
+	MaxMapLen    int   // max number of map entries
+	MaxStringLen int   // max length of strings in bytes
+	MaxDepth     int   // max nesting depth of slices, arrays, maps, structs and interfaces
+}
+
+// DefaultLimits is the Limits of new Decoders, Decode and Read.
+// Change it before decoding or use Decoder.SetLimits to change Limits of a Decoder.
+var DefaultLimits = Limits{
+	MaxDepth: 1000,
+}
+
+// LimitError is returned by Decoder if input exceeds it's Limits.
+type LimitError struct {
+	Limit string // name of the exceeded field of Limits
+	Value uint64 // value required by input
+	Max   uint64 // the limit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("binary.Decoder.Value: %s exceeded: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+const maxInt = int(^uint(0) >> 1)
+
+// SetLimits set Limits of the Decoder.
+func (decoder *Decoder) SetLimits(limits Limits) {
+	decoder.limits = limits
+}
+
+// Limits returns Limits of the Decoder.
+func (decoder *Decoder) Limits() Limits {
+	return decoder.limits
+}
+
+// SliceLen decode length of a slice or array whose elements are encoded
+// with at least minBits bits each.
+// The length is checked with Limits and remaining input before allocation.
+// It will panic if the check fails.
+func (decoder *Decoder) SliceLen(minBits int) int {
+	return decoder.length("MaxSliceLen", decoder.limits.MaxSliceLen, minBits)
+}
+
+// MapLen decode length of a map whose entries are encoded
+// with at least minBits bits each.
+// The length is checked with Limits and remaining input before allocation.
+// It will panic if the check fails.
+func (decoder *Decoder) MapLen(minBits int) int {
+	return decoder.length("MaxMapLen", decoder.limits.MaxMapLen, minBits)
+}
+
+// length decode a uvarint length and check it with max and remaining input.
+func (decoder *Decoder) length(limit string, max int, bits int) int {
+	s, _ := decoder.Uvarint()
+	return decoder.checkLength(s, limit, max, bits)
+}
+
+// checkLength checks length s with max and remaining input.
+func (decoder *Decoder) checkLength(s uint64, limit string, max int, bits int) int {
+	if max > 0 && s > uint64(max) {
+		panic(&LimitError{Limit: limit, Value: s, Max: uint64(max)})
+	}
+	if s > uint64(maxInt) || bits > 0 && s > uint64(maxInt/bits) {
+		panic(fmt.Errorf("binary.Decoder.Value: length %d overflows int", s))
+	}
+	n := int(s)
+	if bits > 0 {
+		pending := 0 //bits left in current bool byte
+		if decoder.boolBit != 0 {
+			pending = 8 - int(decoder.boolBit)
+		}
+		decoder.ensure((n*bits - pending) / 8)
+	}
+	return n
+}
+
+// ensure checks that there is at least size bytes to decode without consuming them.
+// Decoder from reader reads them into buffer.
+func (decoder *Decoder) ensure(size int) {
+	if size <= 0 || decoder.pos+size <= len(decoder.buff) {
+		return
+	}
+	decoder.checkBytes(size)
+	if decoder.reader != nil {
+		if err := decoder.fill(size); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = decoder.truncated(size)
+			}
+			panic(err)
+		}
+		return
+	}
+	panic(decoder.truncated(size))
+}
+
+// checkBytes checks if the next size bytes exceed Limits.MaxBytes.
+func (decoder *Decoder) checkBytes(size int) {
+	if max := decoder.limits.MaxBytes; max > 0 {
+		if n := decoder.Consumed() - decoder.start + int64(size); n > max {
+			panic(&LimitError{Limit: "MaxBytes", Value: uint64(n), Max: uint64(max)})
+		}
+	}
+}
+
+// enter a nested value, it checks Limits.MaxDepth.
+func (decoder *Decoder) enter() {
+	decoder.depth++
+	if max := decoder.limits.MaxDepth; max > 0 && decoder.depth > max {
+		panic(&LimitError{Limit: "MaxDepth", Value: uint64(decoder.depth), Max: uint64(max)})
+	}
+}
+
+// leave a nested value.
+func (decoder *Decoder) leave() {
+	decoder.depth--
+}
+
+// minBitsOf returns the minimum number of bits to encode a value of type t.
+func minBitsOf(t reflect.Type, packed bool) int {
+	if std := stdTypeOf(t); std != nil {
+		return std.minBits
+	}
+	if customOf(t) != customNone {
+		if t.Kind() == reflect.Ptr { //nil flag
+			return 1
+		}
+		return 8 //length
+	}
+	if s := fixedTypeSize(t); s > 0 {
+		if packed && packedIntsType(t) > 0 {
+			return 8
+		}
+		return s * 8
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Ptr: //bool and nil flag are bits
+		return 1
+	case reflect.Struct:
+		info := queryStruct(t)
+		if info.isVersioned() { //terminator
+			return 8
+		}
+		sum := 0
+		for i, n := 0, t.NumField(); i < n; i++ {
+			if f := info.field(i); f.isValid(i, t) {
+				if f.hasLength() {
+					sum += f.length.minBits(f.Type(i, t), f.isPacked())
+				} else if f.hasBits() {
+					sum += f.bits.minSize() * 8
+				} else {
+					sum += minBitsOf(f.Type(i, t), f.isPacked())
+				}
+			}
+		}
+		return sum
+	}
+	return 8 //varint, length or type tag
+}
@@ -0,0 +1,114 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// hostile returns a uvarint length n followed by a few bytes.
+func hostile(n uint64) []byte {
+	b := make([]byte, MaxVarintLen64+3)
+	l := PutUvarint(b, n)
+	return b[:l+3]
+}
+
+func TestHostileLength(t *testing.T) {
+	type empty struct{}
+	var (
+		s   string
+		u8  []uint8
+		u64 []uint64
+		bs  []bool
+		ss  []string
+		st  []struct{ A, B uint32 }
+		m   map[uint32]string
+		arr [2]uint16
+		e   []empty
+	)
+	for _, n := range []uint64{1 << 20, 1 << 40, 1<<63 - 1, 1<<64 - 1} {
+		b := hostile(n)
+		for _, x := range []interface{}{&s, &u8, &u64, &bs, &ss, &st, &m, &arr} {
+			if err := Decode(b, x); err == nil {
+				t.Errorf("Decode %T len %d: have err == nil, want non-nil", x, n)
+			}
+			if err := Read(bytes.NewReader(b), DefaultEndian, x); err == nil {
+				t.Errorf("Read %T len %d: have err == nil, want non-nil", x, n)
+			}
+			if err := NewStreamDecoder(bytes.NewReader(b)).Value(x); err == nil {
+				t.Errorf("StreamDecoder %T len %d: have err == nil, want non-nil", x, n)
+			}
+		}
+	}
+
+	//empty elements take no input
+	b := hostile(1 << 20)
+	if err := Decode(b, &e); err != nil || len(e) != 1<<20 {
+		t.Errorf("Decode empty elements: have len %d err %v, want len %d", len(e), err, 1<<20)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	type nested struct {
+		A []uint8
+		B map[uint8]uint8
+		C string
+		D [][]uint8
+	}
+	data := nested{
+		A: []uint8{1, 2, 3, 4},
+		B: map[uint8]uint8{1: 1, 2: 2, 3: 3},
+		C: "hello",
+		D: [][]uint8{{1}},
+	}
+	b, err := Encode(&data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		limits Limits
+		limit  string
+	}{
+		{Limits{}, ""},
+		{Limits{MaxSliceLen: 4, MaxMapLen: 3, MaxStringLen: 5, MaxDepth: 3, MaxBytes: int64(len(b))}, ""},
+		{Limits{MaxSliceLen: 3}, "MaxSliceLen"},
+		{Limits{MaxMapLen: 2}, "MaxMapLen"},
+		{Limits{MaxStringLen: 4}, "MaxStringLen"},
+		{Limits{MaxDepth: 2}, "MaxDepth"},
+		{Limits{MaxBytes: int64(len(b)) - 1}, "MaxBytes"},
+	}
+	for i, c := range cases {
+		decoder := NewDecoder(b)
+		decoder.SetLimits(c.limits)
+		var r nested
+		err := decoder.Value(&r)
+		if c.limit == "" {
+			if err != nil {
+				t.Errorf("case %d: %v", i, err)
+			}
+			continue
+		}
+		var e *LimitError
+		if !errors.As(err, &e) || e.Limit != c.limit {
+			t.Errorf("case %d: have err %v, want %s exceeded", i, err, c.limit)
+		}
+	}
+}
+
+func TestLimitsDepth(t *testing.T) {
+	//nested interfaces are limited by input only
+	b := bytes.Repeat([]byte{ifaceName, 6, 's', 'l', 'i', 'c', 'e', 's', 1}, 100000)
+	var x interface{}
+	err := Decode(b, &x)
+	var e *LimitError
+	if !errors.As(err, &e) || e.Limit != "MaxDepth" {
+		t.Errorf("have err %v, want MaxDepth exceeded", err)
+	}
+}
+
+func init() {
+	if err := RegisterName("slices", []interface{}{}); err != nil {
+		panic(err)
+	}
+}
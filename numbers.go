@@ -0,0 +1,126 @@
+// bulk copy of fixed-size numbers between memory and buffer.
+
+package binary
+
+import (
+	std "encoding/binary"
+	"unsafe"
+)
+
+// nativeEndian is the byte order of the host.
+// Numbers in this order are copied from memory as they are.
+var nativeEndian = func() Endian {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return LittleEndian
+	}
+	return BigEndian
+}()
+
+// byteOrderOf returns LittleEndian or BigEndian that endian is, include the ones
+// of encoding/binary, or nil for Endian of user.
+func byteOrderOf(endian Endian) Endian {
+	switch endian {
+	case LittleEndian, std.LittleEndian:
+		return LittleEndian
+	case BigEndian, std.BigEndian:
+		return BigEndian
+	}
+	return nil
+}
+
+// numbersOf returns memory of slice at ptr whose elements are size bytes.
+func numbersOf(ptr unsafe.Pointer, size int) []byte {
+	s := (*sliceHeader)(ptr)
+	return unsafe.Slice((*byte)(s.data), s.len*size)
+}
+
+// writeNumbers encode numbers of size bytes in memory mem to Encoder buffer.
+// Stream Encoder writes them in chunks as large as the buffer can hold,
+// and Encoder of fixed buffer writes the ones that fit before it overflows.
+func (encoder *Encoder) writeNumbers(mem []byte, size int) {
+	for (encoder.writer != nil || !encoder.autoGrow) && encoder.pos+len(mem) > len(encoder.buff) {
+		n := (len(encoder.buff) - encoder.pos) / size * size
+		if n == 0 { //reserve flushes the buffer, or panics
+			n = size
+		}
+		putNumbers(encoder.reserve(n), mem[:n], size, encoder.endian)
+		mem = mem[n:]
+	}
+	putNumbers(encoder.reserve(len(mem)), mem, size, encoder.endian)
+}
+
+// readNumbers decode numbers of size bytes from Decoder buffer to memory mem.
+// Numbers more than len(mem) are skipped, n is the number of bytes of them all.
+func (decoder *Decoder) readNumbers(mem []byte, n int, size int) {
+	b := decoder.reserve(n)
+	if len(mem) > n {
+		mem = mem[:n]
+	}
+	getNumbers(mem, b[:len(mem)], size, decoder.endian)
+}
+
+// putNumbers put numbers of size bytes in memory mem to b in endian.
+// They are copied at once in nativeEndian, or byte-swapped in the opposite one.
+func putNumbers(b, mem []byte, size int, endian Endian) {
+	if order := byteOrderOf(endian); order != nil {
+		copy(b, mem)
+		if size > 1 && order != nativeEndian {
+			swapNumbers(b, size)
+		}
+		return
+	}
+	for i := 0; i < len(mem); i += size { //Endian of user
+		switch size {
+		case 1:
+			b[i] = mem[i]
+		case 2:
+			endian.PutUint16(b[i:], *(*uint16)(unsafe.Pointer(&mem[i])))
+		case 4:
+			endian.PutUint32(b[i:], *(*uint32)(unsafe.Pointer(&mem[i])))
+		default:
+			endian.PutUint64(b[i:], *(*uint64)(unsafe.Pointer(&mem[i])))
+		}
+	}
+}
+
+// getNumbers get numbers of size bytes from b in endian to memory mem.
+func getNumbers(mem, b []byte, size int, endian Endian) {
+	if order := byteOrderOf(endian); order != nil {
+		copy(mem, b)
+		if size > 1 && order != nativeEndian {
+			swapNumbers(mem, size)
+		}
+		return
+	}
+	for i := 0; i < len(mem); i += size { //Endian of user
+		switch size {
+		case 1:
+			mem[i] = b[i]
+		case 2:
+			*(*uint16)(unsafe.Pointer(&mem[i])) = endian.Uint16(b[i:])
+		case 4:
+			*(*uint32)(unsafe.Pointer(&mem[i])) = endian.Uint32(b[i:])
+		default:
+			*(*uint64)(unsafe.Pointer(&mem[i])) = endian.Uint64(b[i:])
+		}
+	}
+}
+
+// swapNumbers reverse bytes of each number of size bytes in b.
+func swapNumbers(b []byte, size int) {
+	switch size {
+	case 2:
+		for i := 0; i+2 <= len(b); i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	case 4:
+		for i := 0; i+4 <= len(b); i += 4 {
+			LittleEndian.PutUint32(b[i:], BigEndian.Uint32(b[i:]))
+		}
+	case 8:
+		for i := 0; i+8 <= len(b); i += 8 {
+			LittleEndian.PutUint64(b[i:], BigEndian.Uint64(b[i:]))
+		}
+	}
+}
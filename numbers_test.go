@@ -0,0 +1,186 @@
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type numberSamples []float32
+
+type numberStruct struct {
+	A  numberSamples
+	B  [5]uint16
+	C  []complex64
+	D  [2]int64 `binary:"be"`
+	E  []int32
+	F  [3]float64
+	G  []uint8
+	Is []int
+}
+
+// swappedEndian is an Endian of user, that is a big-endian.
+type swappedEndian struct{}
+
+func (swappedEndian) Uint16(b []byte) uint16       { return BigEndian.Uint16(b) }
+func (swappedEndian) PutUint16(b []byte, v uint16) { BigEndian.PutUint16(b, v) }
+func (swappedEndian) Uint32(b []byte) uint32       { return BigEndian.Uint32(b) }
+func (swappedEndian) PutUint32(b []byte, v uint32) { BigEndian.PutUint32(b, v) }
+func (swappedEndian) Uint64(b []byte) uint64       { return BigEndian.Uint64(b) }
+func (swappedEndian) PutUint64(b []byte, v uint64) { BigEndian.PutUint64(b, v) }
+func (swappedEndian) String() string               { return "swappedEndian" }
+
+func newNumberStruct() numberStruct {
+	return numberStruct{
+		A:  numberSamples{1.5, -2.25, 3},
+		B:  [5]uint16{1, 0x0203, 0xfffe},
+		C:  []complex64{complex(1, -1), complex(2.5, 3)},
+		D:  [2]int64{-1, 0x0102030405060708},
+		E:  []int32{-2, 0x01020304},
+		F:  [3]float64{0.5, -1e10, 7},
+		G:  []uint8{9, 8},
+		Is: []int{-300, 300},
+	}
+}
+
+// encodeNumbers encode x number by number, to check the bulk way.
+func encodeNumbers(x *numberStruct, endian Endian) []byte {
+	encoder := NewEncoderEndian(0, endian)
+	encoder.SetAutoGrow(true)
+	encoder.Uvarint(uint64(len(x.A)))
+	for _, v := range x.A {
+		encoder.Float32(v)
+	}
+	encoder.Uvarint(uint64(len(x.B)))
+	for _, v := range x.B {
+		encoder.Uint16(v, false)
+	}
+	encoder.Uvarint(uint64(len(x.C)))
+	for _, v := range x.C {
+		encoder.Complex64(v)
+	}
+	encoder.Uvarint(uint64(len(x.D)))
+	for _, v := range x.D {
+		BigEndian.PutUint64(encoder.reserve(8), uint64(v))
+	}
+	encoder.Uvarint(uint64(len(x.E)))
+	for _, v := range x.E {
+		encoder.Int32(v, false)
+	}
+	encoder.Uvarint(uint64(len(x.F)))
+	for _, v := range x.F {
+		encoder.Float64(v)
+	}
+	encoder.Uvarint(uint64(len(x.G)))
+	for _, v := range x.G {
+		encoder.Uint8(v)
+	}
+	encoder.Uvarint(uint64(len(x.Is)))
+	for _, v := range x.Is {
+		encoder.Int(v)
+	}
+	return encoder.Buffer()
+}
+
+func TestNumbers(t *testing.T) {
+	x := newNumberStruct()
+	for _, endian := range []Endian{LittleEndian, BigEndian, swappedEndian{}} {
+		want := encodeNumbers(&x, endian)
+		var w bytes.Buffer
+		if err := Write(&w, endian, x); err != nil || !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("Write(%v): have %v, %v, want %v", endian, w.Bytes(), err, want)
+		}
+		if s := Sizeof(x); s != len(want) {
+			t.Errorf("Sizeof: have %d, want %d", s, len(want))
+		}
+		var y numberStruct
+		if err := Read(&w, endian, &y); err != nil || !reflect.DeepEqual(y, x) {
+			t.Errorf("Read(%v): have %+v, %v", endian, y, err)
+		}
+	}
+}
+
+func TestNumbersFast(t *testing.T) {
+	cases := []interface{}{
+		[]int8{-1, 2},
+		[]uint8{1, 2},
+		[]int16{-1, 0x0102},
+		[]uint16{1, 0x0102},
+		[]int32{-1, 0x01020304},
+		[]uint32{1, 0x01020304},
+		[]int64{-1, 0x0102030405060708},
+		[]uint64{1, 0x0102030405060708},
+		[]float32{1.5, -2},
+		[]float64{1.5, -2},
+		[]complex64{complex(1, 2)},
+		[]complex128{complex(1, 2)},
+	}
+	for _, endian := range []Endian{LittleEndian, BigEndian, swappedEndian{}} {
+		for _, c := range cases {
+			encoder := NewEncoderEndian(Sizeof(c), endian)
+			v := reflect.ValueOf(c)
+			encoder.Uvarint(uint64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				if err := encoder.Value(v.Index(i).Interface()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want := encoder.Buffer()
+
+			var w bytes.Buffer
+			if err := Write(&w, endian, c); err != nil || !bytes.Equal(w.Bytes(), want) {
+				t.Errorf("Write(%v, %T): have %v, %v, want %v", endian, c, w.Bytes(), err, want)
+			}
+			y := reflect.New(v.Type())
+			if err := Read(&w, endian, y.Interface()); err != nil || !reflect.DeepEqual(y.Elem().Interface(), c) {
+				t.Errorf("Read(%v, %T): have %v, %v", endian, c, y.Elem().Interface(), err)
+			}
+		}
+	}
+}
+
+func TestNumbersStream(t *testing.T) {
+	x := make(numberSamples, 3000) //larger than buffer of stream Encoder
+	for i := range x {
+		x[i] = float32(i) / 3
+	}
+	for _, endian := range []Endian{LittleEndian, BigEndian} {
+		var w bytes.Buffer
+		if err := Write(&w, endian, x); err != nil {
+			t.Fatal(err)
+		}
+		if w.Len() != Sizeof(x) {
+			t.Errorf("Write(%v): have %d bytes, want %d", endian, w.Len(), Sizeof(x))
+		}
+		var y numberSamples
+		if err := Read(&w, endian, &y); err != nil || !reflect.DeepEqual(y, x) {
+			t.Errorf("Read(%v): have %d numbers, %v", endian, len(y), err)
+		}
+	}
+}
+
+func TestNumbersArray(t *testing.T) {
+	type from struct {
+		A []uint32
+		B uint8
+	}
+	type to struct {
+		A [2]uint32
+		B uint8
+	}
+	b, err := Encode(from{A: []uint32{1, 2, 3}, B: 4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var y to
+	if err := Decode(b, &y); err != nil || y.A != [2]uint32{1, 2} || y.B != 4 { //numbers out of array are skipped
+		t.Errorf("Decode: have %+v, %v", y, err)
+	}
+
+	encoder := NewEncoder(10)
+	if err := encoder.Value(numberSamples{1, 2, 3}); err == nil {
+		t.Errorf("Encoder.Value: have err == nil, want overflow")
+	} else if encoder.Len() != 9 { //numbers before overflow are encoded
+		t.Errorf("Encoder.Value: have %d bytes, want 9", encoder.Len())
+	}
+}
@@ -0,0 +1,794 @@
+// compiled codec plans of types, to encode/decode without repeated reflection.
+
+package binary
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// A plan is compiled once for each slice, array and struct type, and cached.
+// It is a tree of op codes that encode, decode, size and skip values by
+// their memory address, so the Kind, validity and registry of each nested
+// type are checked only when it is compiled.
+// Values that the plans can not deal with are delegated to the reflection
+// way by opReflect, such as pointers, maps, interfaces, types that encode
+// themselves, and structs with version, length or bit field tags.
+// Plans are dropped by RegStruct, because registry changes the encoding.
+
+// planOp is the op code of a plan.
+type planOp uint8
+
+const (
+	opReflect planOp = iota //by reflection, it is also the op of a plan being compiled
+	opBool                  //bool bit
+	opFixed                 //n numbers of size bytes, eg: complex64 is 2 numbers of 4 bytes
+	opVarint                //signed integer of size bytes as varint
+	opUvarint               //unsigned integer of size bytes as uvarint
+	opString                //length-prefixed string
+	opSlice                 //length-prefixed elements
+	opArray                 //length-prefixed elements of array with n elements
+	opStruct                //fields
+)
+
+// sliceMode is the way that elements of slice and array are encoded.
+type sliceMode uint8
+
+const (
+	sliceElems   sliceMode = iota //element by element
+	sliceBytes                    //bytes of int8 and uint8 elements are copied
+	sliceBools                    //bits in bytes of their own
+	sliceNumbers                  //fixed-size numbers are copied at once, see numbers.go
+)
+
+// plan is the compiled codec of a type.
+type plan struct {
+	op      planOp
+	t       reflect.Type
+	packed  bool       //encode ints as varint
+	custom  customKind //of opReflect
+	reflect bool       //if opReflect is used by this plan or it's nested plans
+	size    int        //bytes of a number, or bytes of an element of slice and array
+	n       int        //count of numbers, or length of array
+	mode    sliceMode  //of slice and array
+	minBits int        //min bits of an element of slice and array, see minBitsOf
+	elem    *plan      //of slice and array
+	fields  []planField
+}
+
+// planField is an encoded field of a struct plan.
+type planField struct {
+	name   string
+	offset uintptr
+	endian Endian //byte order of be/le tag, nil for Endian of the coder
+	plan   *plan
+	run    int //bytes of this and following fields of opFixed, they are coded at once
+	runN   int //number of fields of run
+}
+
+// planKey is the key of cached plans, it is made by keyOf.
+type planKey uintptr
+
+// keyOf returns planKey of type t, that is the address of it's rtype and the packed flag.
+// Integer key makes lookup of plans much faster than an interface key.
+func keyOf(t reflect.Type, packed bool) planKey {
+	k := planKey((*[2]uintptr)(unsafe.Pointer(&t))[1]) //data word of interface is *rtype, that is never freed
+	if packed {
+		k |= 1 //rtype is aligned
+	}
+	return k
+}
+
+var _planMgr planMgr
+
+func init() {
+	_planMgr.reset()
+}
+
+// planMgr is a copy-on-write cache of plans, like structInfoMgr.
+// Types that have no plan are cached as nil.
+type planMgr struct {
+	mu    sync.Mutex   //lock for writers
+	cache atomic.Value //map[planKey]*plan
+}
+
+func (mgr *planMgr) load() map[planKey]*plan {
+	return mgr.cache.Load().(map[planKey]*plan)
+}
+
+// reset drops all the plans.
+func (mgr *planMgr) reset() {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	mgr.cache.Store(make(map[planKey]*plan))
+}
+
+// planOf returns plan of slice, array or struct type t, or nil if it has no plan.
+func planOf(t reflect.Type, packed bool) *plan {
+	switch t.Kind() {
+	case reflect.Struct:
+		packed = false //fields have their own
+	case reflect.Slice, reflect.Array:
+	default: //not worth a plan
+		return nil
+	}
+	key := keyOf(t, packed)
+	if p, ok := _planMgr.load()[key]; ok {
+		return p
+	}
+	return _planMgr.compile(t, packed)
+}
+
+// compile compiles plan of t, and caches it with it's nested plans.
+func (mgr *planMgr) compile(t reflect.Type, packed bool) *plan {
+	key := keyOf(t, packed)
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	old := mgr.load()
+	if p, ok := old[key]; ok {
+		return p
+	}
+	c := planCompiler{cached: old, plans: make(map[planKey]*plan)}
+	c.compile(t, packed)
+	c.markReflect()
+
+	cache := make(map[planKey]*plan, len(old)+len(c.plans))
+	for k, v := range old {
+		cache[k] = v
+	}
+	for k, p := range c.plans {
+		switch p.t.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Struct: //see planOf
+			if p.op == opReflect {
+				p = nil
+			}
+			cache[k] = p
+		}
+	}
+	mgr.cache.Store(cache)
+	return cache[key]
+}
+
+// planCompiler compiles plan of a type and it's nested types.
+type planCompiler struct {
+	cached map[planKey]*plan //plans that have been compiled
+	plans  map[planKey]*plan //plans compiled by this compiler, include the ones being compiled
+}
+
+func (c *planCompiler) compile(t reflect.Type, packed bool) *plan {
+	if t.Kind() == reflect.Struct {
+		packed = false
+	}
+	key := keyOf(t, packed)
+	if p := c.cached[key]; p != nil {
+		return p
+	}
+	if p, ok := c.plans[key]; ok { //compiled or recursive type
+		return p
+	}
+	p := &plan{t: t, packed: packed}
+	c.plans[key] = p //before compile nested types, to stop recursive types
+	if p.custom = customOf(t); p.custom != customNone || stdTypeOf(t) != nil {
+		return p
+	}
+
+	switch k := t.Kind(); k {
+	case reflect.Bool:
+		p.op = opBool
+	case reflect.Int8, reflect.Uint8, reflect.Float32, reflect.Float64:
+		p.op, p.size, p.n = opFixed, int(t.Size()), 1
+	case reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		p.op, p.size, p.n = opFixed, int(t.Size()), 1
+		if packed {
+			p.op = opUvarint
+			if packedIntsType(t) == _SignedInts {
+				p.op = opVarint
+			}
+		}
+	case reflect.Complex64, reflect.Complex128:
+		p.op, p.size, p.n = opFixed, int(t.Size())/2, 2
+	case reflect.Int:
+		p.op, p.size = opVarint, int(t.Size())
+	case reflect.Uint:
+		p.op, p.size = opUvarint, int(t.Size())
+	case reflect.String:
+		p.op = opString
+	case reflect.Slice, reflect.Array:
+		et := t.Elem()
+		if !validUserType(et) { //reflection returns the error
+			return p
+		}
+		p.size = int(et.Size())
+		p.minBits = minBitsOf(et, packed)
+		p.elem = c.compile(et, packed)
+		switch ek := et.Kind(); {
+		case ek == reflect.Bool:
+			p.mode = sliceBools
+		case (ek == reflect.Uint8 || ek == reflect.Int8) && customOf(et) == customNone:
+			p.mode = sliceBytes
+		case p.elem.op == opFixed:
+			p.mode = sliceNumbers
+		}
+		p.op = opSlice
+		if k == reflect.Array {
+			p.op, p.n = opArray, t.Len()
+		}
+	case reflect.Struct:
+		info := queryStruct(t)
+		if info == nil || info.err != nil || info.isVersioned() {
+			return p
+		}
+		fields := make([]planField, 0, len(info.fields))
+		for _, f := range info.fields {
+			if f.ignore {
+				continue
+			}
+			if f.hasLength() || f.hasBits() { //they need the struct
+				return p
+			}
+			fields = append(fields, planField{
+				name:   f.field.Name,
+				offset: f.field.Offset,
+				endian: f.endian,
+				plan:   c.compile(f.field.Type, f.isPacked()),
+			})
+		}
+		for i := len(fields) - 1; i >= 0; i-- {
+			if f := &fields[i]; f.plan.op == opFixed {
+				f.run, f.runN = f.plan.size*f.plan.n, 1
+				if i+1 < len(fields) {
+					f.run, f.runN = f.run+fields[i+1].run, f.runN+fields[i+1].runN
+				}
+			}
+		}
+		p.op, p.fields = opStruct, fields
+	}
+	return p
+}
+
+// markReflect set plan.reflect of the compiled plans.
+// It repeats until nothing changes, because recursive types make loops.
+func (c *planCompiler) markReflect() {
+	for _, p := range c.plans {
+		p.reflect = p.op == opReflect
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, p := range c.plans {
+			if p.reflect {
+				continue
+			}
+			r := p.elem != nil && p.elem.reflect
+			for i := 0; !r && i < len(p.fields); i++ {
+				r = p.fields[i].plan.reflect
+			}
+			if r {
+				p.reflect, changed = true, true
+			}
+		}
+	}
+}
+
+// planPtr returns address of v for plan p, or nil if v can not use the plan.
+// Value that is not addressable refers to the memory of an interface,
+// it use the plan only if it needs no reflection, because methods of
+// custom types may modify the value.
+func planPtr(v reflect.Value, p *plan) unsafe.Pointer {
+	if v.CanAddr() {
+		return unsafe.Pointer(v.UnsafeAddr())
+	}
+	if p.reflect || !v.CanInterface() {
+		return nil
+	}
+	x := v.Interface() //types that need no reflection are never stored in interface directly
+	return (*[2]unsafe.Pointer)(unsafe.Pointer(&x))[1]
+}
+
+// sliceData returns address of the first element and length of slice or array at ptr.
+func (p *plan) sliceData(ptr unsafe.Pointer) (unsafe.Pointer, int) {
+	if p.op == opArray {
+		return ptr, p.n
+	}
+	s := (*sliceHeader)(ptr)
+	return s.data, s.len
+}
+
+// sliceHeader is the memory layout of slices.
+type sliceHeader struct {
+	data unsafe.Pointer
+	len  int
+	cap  int
+}
+
+// elemAt returns address of element i of slice or array data.
+func (p *plan) elemAt(data unsafe.Pointer, i int) unsafe.Pointer {
+	return unsafe.Add(data, i*p.size)
+}
+
+// loadInt returns signed integer of size bytes at ptr.
+func loadInt(ptr unsafe.Pointer, size int) int64 {
+	switch size {
+	case 1:
+		return int64(*(*int8)(ptr))
+	case 2:
+		return int64(*(*int16)(ptr))
+	case 4:
+		return int64(*(*int32)(ptr))
+	}
+	return *(*int64)(ptr)
+}
+
+// loadUint returns unsigned integer of size bytes at ptr.
+func loadUint(ptr unsafe.Pointer, size int) uint64 {
+	switch size {
+	case 1:
+		return uint64(*(*uint8)(ptr))
+	case 2:
+		return uint64(*(*uint16)(ptr))
+	case 4:
+		return uint64(*(*uint32)(ptr))
+	}
+	return *(*uint64)(ptr)
+}
+
+// storeUint set integer of size bytes at ptr to low bits of x.
+func storeUint(ptr unsafe.Pointer, size int, x uint64) {
+	switch size {
+	case 1:
+		*(*uint8)(ptr) = uint8(x)
+	case 2:
+		*(*uint16)(ptr) = uint16(x)
+	case 4:
+		*(*uint32)(ptr) = uint32(x)
+	default:
+		*(*uint64)(ptr) = x
+	}
+}
+
+// bitsOf returns number of bits to encode value of p at ptr, or -1 if it fails.
+func (p *plan) bitsOf(ptr unsafe.Pointer) int {
+	switch p.op {
+	case opBool:
+		return 1
+	case opFixed:
+		return p.size * p.n * 8
+	case opVarint:
+		return SizeofVarint(loadInt(ptr, p.size)) * 8
+	case opUvarint:
+		return SizeofUvarint(loadUint(ptr, p.size)) * 8
+	case opString:
+		return sizeofString(len(*(*string)(ptr))) * 8
+	case opSlice, opArray:
+		data, n := p.sliceData(ptr)
+		switch {
+		case p.mode == sliceBools:
+			return sizeofBoolArray(n) * 8
+		case p.elem.op == opFixed:
+			return sizeofFixArray(n, p.elem.size*p.elem.n) * 8
+		}
+		sum := SizeofUvarint(uint64(n)) * 8
+		for i := 0; i < n; i++ {
+			s := p.elem.bitsOf(p.elemAt(data, i))
+			if s < 0 {
+				return -1
+			}
+			sum += s
+		}
+		return sum
+	case opStruct:
+		sum := 0
+		for i := range p.fields {
+			f := &p.fields[i]
+			s := f.plan.bitsOf(unsafe.Add(ptr, f.offset))
+			if s < 0 {
+				return -1
+			}
+			sum += s
+		}
+		return sum
+	}
+	return bitsOfElem(reflect.NewAt(p.t, ptr).Elem(), p.custom, p.packed)
+}
+
+// plan encode value of p at ptr.
+func (encoder *Encoder) plan(p *plan, ptr unsafe.Pointer) {
+	switch p.op {
+	case opBool:
+		encoder.Bool(*(*bool)(ptr))
+	case opFixed:
+		encoder.fixed(p, ptr)
+	case opVarint:
+		encoder.Varint(loadInt(ptr, p.size))
+	case opUvarint:
+		encoder.Uvarint(loadUint(ptr, p.size))
+	case opString:
+		encoder.String(*(*string)(ptr))
+	case opSlice, opArray:
+		encoder.planSlice(p, ptr)
+	case opStruct:
+		encoder.planStruct(p, ptr)
+	default:
+		encoder.elem(reflect.NewAt(p.t, ptr).Elem(), p.custom, p.packed)
+	}
+}
+
+// fixed encode numbers of opFixed plan p at ptr.
+func (encoder *Encoder) fixed(p *plan, ptr unsafe.Pointer) {
+	if size := p.size * p.n; encoder.pos+size <= len(encoder.buff) {
+		putFixed(encoder.coder.reserve(size), encoder.endian, p, ptr)
+		return
+	}
+	for i := 0; i < p.n; i++ {
+		q := unsafe.Add(ptr, i*p.size)
+		switch p.size {
+		case 1:
+			encoder.Uint8(*(*uint8)(q))
+		case 2:
+			encoder.Uint16(*(*uint16)(q), false)
+		case 4:
+			encoder.Uint32(*(*uint32)(q), false)
+		default:
+			encoder.Uint64(*(*uint64)(q), false)
+		}
+	}
+}
+
+// planSlice encode slice or array of p at ptr, see Encoder.slice.
+func (encoder *Encoder) planSlice(p *plan, ptr unsafe.Pointer) {
+	data, n := p.sliceData(ptr)
+	encoder.Uvarint(uint64(n))
+	switch p.mode {
+	case sliceBytes:
+		encoder.writeBytes(unsafe.Slice((*byte)(data), n))
+		return
+	case sliceNumbers:
+		encoder.writeNumbers(unsafe.Slice((*byte)(data), n*p.size), p.elem.size)
+		return
+	case sliceBools:
+		var b []byte
+		for i := 0; i < n; i++ {
+			if i%8 == 0 {
+				b = encoder.reserve(1)
+				b[0] = 0
+			}
+			if *(*bool)(p.elemAt(data, i)) {
+				b[0] |= 1 << uint(i%8)
+			}
+		}
+		return
+	}
+
+	i, offset := 0, encoder.offset()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, indexPath(i), p.elem.t, offset)
+		}
+	}()
+	for ; i < n; i++ {
+		offset = encoder.offset()
+		encoder.plan(p.elem, p.elemAt(data, i))
+	}
+}
+
+// planStruct encode struct of p at ptr, see structInfo.encode.
+func (encoder *Encoder) planStruct(p *plan, ptr unsafe.Pointer) {
+	i, offset := 0, encoder.offset()
+	endian := encoder.endian //fields with be/le tag change it
+	defer func() {
+		encoder.endian = endian
+		if e := recover(); e != nil {
+			tracePath(e, "."+p.fields[i].name, p.fields[i].plan.t, offset)
+		}
+	}()
+	for ; i < len(p.fields); i++ {
+		f := &p.fields[i]
+		if f.run > 0 && encoder.pos+f.run <= len(encoder.buff) { //fixed fields that never fail
+			putFields(encoder.coder.reserve(f.run), p.fields[i:i+f.runN], endian, ptr)
+			i += f.runN - 1
+			continue
+		}
+		offset = encoder.offset()
+		encoder.endian = endian
+		if f.endian != nil {
+			encoder.endian = f.endian
+		}
+		encoder.plan(f.plan, unsafe.Add(ptr, f.offset))
+	}
+}
+
+// plan decode value of p to ptr.
+func (decoder *Decoder) plan(p *plan, ptr unsafe.Pointer) {
+	switch p.op {
+	case opBool:
+		*(*bool)(ptr) = decoder.Bool()
+	case opFixed:
+		decoder.fixed(p, ptr)
+	case opVarint:
+		x, _ := decoder.Varint()
+		storeUint(ptr, p.size, uint64(x))
+	case opUvarint:
+		x, _ := decoder.Uvarint()
+		storeUint(ptr, p.size, x)
+	case opString:
+		*(*string)(ptr) = decoder.String()
+	case opSlice, opArray:
+		decoder.planSlice(p, ptr)
+	case opStruct:
+		decoder.enter()
+		decoder.planStruct(p, ptr)
+		decoder.leave()
+	default:
+		decoder.elem(reflect.NewAt(p.t, ptr).Elem(), p.custom, p.packed)
+	}
+}
+
+// fixed decode numbers of opFixed plan p to ptr.
+func (decoder *Decoder) fixed(p *plan, ptr unsafe.Pointer) {
+	if size := p.size * p.n; decoder.buffered(size) {
+		getFixed(decoder.coder.reserve(size), decoder.endian, p, ptr)
+		return
+	}
+	for i := 0; i < p.n; i++ {
+		q := unsafe.Add(ptr, i*p.size)
+		switch p.size {
+		case 1:
+			*(*uint8)(q) = decoder.Uint8()
+		case 2:
+			*(*uint16)(q) = decoder.Uint16(false)
+		case 4:
+			*(*uint32)(q) = decoder.Uint32(false)
+		default:
+			*(*uint64)(q) = decoder.Uint64(false)
+		}
+	}
+}
+
+// planSlice decode slice or array of p to ptr, see Decoder.slice.
+func (decoder *Decoder) planSlice(p *plan, ptr unsafe.Pointer) {
+	i, offset := -1, decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			t := p.t
+			if i >= 0 {
+				t = p.elem.t
+			}
+			tracePath(e, indexPath(i), t, offset)
+		}
+	}()
+
+	decoder.enter()
+	bits := p.minBits
+	if p.mode == sliceBools {
+		bits = 1
+	}
+	size := decoder.SliceLen(bits)
+	if p.mode == sliceBytes && p.op == opSlice && size > 0 && p.elem.t.Kind() == reflect.Uint8 {
+		*(*[]byte)(ptr) = decoder.makeBytes(decoder.reserve(size)) //may refer to the buffer
+		decoder.leave()
+		return
+	}
+	if size > 0 && p.op == opSlice { //make a new slice
+		reflect.NewAt(p.t, ptr).Elem().Set(reflect.MakeSlice(p.t, size, size))
+	}
+	data, l := p.sliceData(ptr)
+
+	switch p.mode {
+	case sliceBytes:
+		copy(unsafe.Slice((*byte)(data), l), decoder.reserve(size)) //bytes out of array are skipped
+	case sliceNumbers:
+		decoder.readNumbers(unsafe.Slice((*byte)(data), l*p.size), size*p.size, p.elem.size)
+	case sliceBools:
+		var b []byte
+		for j := 0; j < size; j++ {
+			if j%8 == 0 {
+				b = decoder.reserve(1)
+			}
+			if j < l { //skip bits out of array
+				*(*bool)(p.elemAt(data, j)) = b[0]&(1<<uint(j%8)) != 0
+			}
+		}
+	default:
+		if bits == 0 { //nothing to decode for empty elements
+			size = 0
+		}
+		for i = 0; i < size; i++ {
+			offset = decoder.Consumed()
+			if i < l {
+				decoder.plan(p.elem, p.elemAt(data, i))
+			} else {
+				decoder.skipPlan(p.elem)
+			}
+		}
+	}
+	decoder.leave()
+}
+
+// planStruct decode struct of p to ptr, see structInfo.decode.
+func (decoder *Decoder) planStruct(p *plan, ptr unsafe.Pointer) {
+	i, offset := 0, decoder.Consumed()
+	endian := decoder.endian //fields with be/le tag change it
+	defer func() {
+		decoder.endian = endian
+		if e := recover(); e != nil {
+			tracePath(e, "."+p.fields[i].name, p.fields[i].plan.t, offset)
+		}
+	}()
+	for ; i < len(p.fields); i++ {
+		f := &p.fields[i]
+		if f.run > 0 && decoder.buffered(f.run) { //fixed fields that never fail
+			getFields(decoder.coder.reserve(f.run), p.fields[i:i+f.runN], endian, ptr)
+			i += f.runN - 1
+			continue
+		}
+		offset = decoder.Consumed()
+		decoder.endian = endian
+		if f.endian != nil {
+			decoder.endian = f.endian
+		}
+		decoder.plan(f.plan, unsafe.Add(ptr, f.offset))
+	}
+}
+
+// buffered returns if the next size bytes can be decoded from buffer without error.
+func (decoder *Decoder) buffered(size int) bool {
+	if decoder.pos+size > len(decoder.buff) {
+		return false
+	}
+	max := decoder.limits.MaxBytes
+	return max <= 0 || decoder.Consumed()-decoder.start+int64(size) <= max
+}
+
+// putFixed put numbers of opFixed plan p at ptr to b, it returns the rest of b.
+// Endians of this package are called directly, so that they are inlined.
+func putFixed(b []byte, endian Endian, p *plan, ptr unsafe.Pointer) []byte {
+	for i := 0; i < p.n; i++ {
+		q := unsafe.Add(ptr, i*p.size)
+		switch p.size {
+		case 1:
+			b[0] = *(*uint8)(q)
+		case 2:
+			switch endian.(type) {
+			case littleEndian:
+				LittleEndian.PutUint16(b, *(*uint16)(q))
+			case bigEndian:
+				BigEndian.PutUint16(b, *(*uint16)(q))
+			default:
+				endian.PutUint16(b, *(*uint16)(q))
+			}
+		case 4:
+			switch endian.(type) {
+			case littleEndian:
+				LittleEndian.PutUint32(b, *(*uint32)(q))
+			case bigEndian:
+				BigEndian.PutUint32(b, *(*uint32)(q))
+			default:
+				endian.PutUint32(b, *(*uint32)(q))
+			}
+		default:
+			switch endian.(type) {
+			case littleEndian:
+				LittleEndian.PutUint64(b, *(*uint64)(q))
+			case bigEndian:
+				BigEndian.PutUint64(b, *(*uint64)(q))
+			default:
+				endian.PutUint64(b, *(*uint64)(q))
+			}
+		}
+		b = b[p.size:]
+	}
+	return b
+}
+
+// getFixed get numbers of opFixed plan p from b to ptr, it returns the rest of b.
+func getFixed(b []byte, endian Endian, p *plan, ptr unsafe.Pointer) []byte {
+	for i := 0; i < p.n; i++ {
+		q := unsafe.Add(ptr, i*p.size)
+		switch p.size {
+		case 1:
+			*(*uint8)(q) = b[0]
+		case 2:
+			switch endian.(type) {
+			case littleEndian:
+				*(*uint16)(q) = LittleEndian.Uint16(b)
+			case bigEndian:
+				*(*uint16)(q) = BigEndian.Uint16(b)
+			default:
+				*(*uint16)(q) = endian.Uint16(b)
+			}
+		case 4:
+			switch endian.(type) {
+			case littleEndian:
+				*(*uint32)(q) = LittleEndian.Uint32(b)
+			case bigEndian:
+				*(*uint32)(q) = BigEndian.Uint32(b)
+			default:
+				*(*uint32)(q) = endian.Uint32(b)
+			}
+		default:
+			switch endian.(type) {
+			case littleEndian:
+				*(*uint64)(q) = LittleEndian.Uint64(b)
+			case bigEndian:
+				*(*uint64)(q) = BigEndian.Uint64(b)
+			default:
+				*(*uint64)(q) = endian.Uint64(b)
+			}
+		}
+		b = b[p.size:]
+	}
+	return b
+}
+
+// putFields put fixed fields of struct at ptr to b.
+func putFields(b []byte, fields []planField, endian Endian, ptr unsafe.Pointer) {
+	for i := range fields {
+		f := &fields[i]
+		if f.endian != nil {
+			b = putFixed(b, f.endian, f.plan, unsafe.Add(ptr, f.offset))
+		} else {
+			b = putFixed(b, endian, f.plan, unsafe.Add(ptr, f.offset))
+		}
+	}
+}
+
+// getFields get fixed fields of struct from b to ptr.
+func getFields(b []byte, fields []planField, endian Endian, ptr unsafe.Pointer) {
+	for i := range fields {
+		f := &fields[i]
+		if f.endian != nil {
+			b = getFixed(b, f.endian, f.plan, unsafe.Add(ptr, f.offset))
+		} else {
+			b = getFixed(b, endian, f.plan, unsafe.Add(ptr, f.offset))
+		}
+	}
+}
+
+// skipPlan skip a value of p, it returns number of bytes skipped.
+func (decoder *Decoder) skipPlan(p *plan) int {
+	start := decoder.Consumed()
+	switch p.op {
+	case opBool:
+		decoder.Bool()
+	case opFixed:
+		decoder.Skip(p.size * p.n)
+	case opVarint, opUvarint:
+		decoder.Uvarint()
+	case opString:
+		decoder.Skip(decoder.length("MaxStringLen", decoder.limits.MaxStringLen, 8))
+	case opSlice, opArray:
+		decoder.enter()
+		defer decoder.leave()
+		if p.mode == sliceBools {
+			decoder.Skip((decoder.SliceLen(1) + 7) / 8)
+			break
+		}
+		n := decoder.SliceLen(p.minBits)
+		if p.elem.op == opFixed {
+			decoder.Skip(n * p.elem.size * p.elem.n)
+		} else if p.minBits > 0 { //nothing to skip for empty elements
+			for i := 0; i < n; i++ {
+				decoder.skipPlan(p.elem)
+			}
+		}
+	case opStruct:
+		decoder.enter()
+		defer decoder.leave()
+		endian := decoder.endian //fields with be/le tag change it
+		defer func() {
+			decoder.endian = endian
+		}()
+		for i := range p.fields {
+			f := &p.fields[i]
+			decoder.endian = endian
+			if f.endian != nil {
+				decoder.endian = f.endian
+			}
+			decoder.skipPlan(f.plan)
+		}
+	default:
+		return decoder.skipByType(p.t, p.packed)
+	}
+	return int(decoder.Consumed() - start)
+}
@@ -0,0 +1,173 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type planInner struct {
+	A uint16 `binary:"be"`
+	B int8
+}
+
+type planFixed struct {
+	A uint16 `binary:"be"`
+	B int8
+	C [2]uint16
+	D bool
+	E []bool
+	F []byte
+	G int
+}
+
+type planNested struct {
+	N    int
+	U    uint
+	S    string
+	In   planInner
+	Ins  []planInner
+	A    [3]int16
+	AA   [2][2]uint8
+	Bs   []byte
+	Bo   []bool
+	C    complex64
+	F    float64
+	P    *planInner
+	M    map[string]uint8
+	Pt   point
+	Pts  []point
+	Skip int32 `binary:"ignore"`
+}
+
+type planPacked struct {
+	X uint32 `binary:"packed"`
+	Y int64  `binary:"packed"`
+	Z []uint16
+}
+
+func TestPlan(t *testing.T) {
+	x := planFixed{A: 0x0102, B: -1, C: [2]uint16{3, 4}, D: true, E: []bool{true, false, true}, F: []byte{5, 6}, G: -2}
+	cases := []struct {
+		endian Endian
+		want   []byte
+	}{
+		{LittleEndian, []byte{1, 2, 0xff, 2, 3, 0, 4, 0, 1, 3, 5, 2, 5, 6, 3}},
+		{BigEndian, []byte{1, 2, 0xff, 2, 0, 3, 0, 4, 1, 3, 5, 2, 5, 6, 3}},
+	}
+	for _, c := range cases {
+		var w bytes.Buffer
+		if err := Write(&w, c.endian, x); err != nil || !bytes.Equal(w.Bytes(), c.want) {
+			t.Errorf("Write(%v): have %v, %v, want %v", c.endian, w.Bytes(), err, c.want)
+		}
+		var y planFixed
+		if err := Read(&w, c.endian, &y); err != nil || !reflect.DeepEqual(y, x) {
+			t.Errorf("Read(%v): have %+v, %v", c.endian, y, err)
+		}
+	}
+	if s := Sizeof(x); s != len(cases[0].want) {
+		t.Errorf("Sizeof: have %d, want %d", s, len(cases[0].want))
+	}
+}
+
+func TestPlanNested(t *testing.T) {
+	x := planNested{
+		N:   -300,
+		U:   300,
+		S:   "str",
+		In:  planInner{1, 2},
+		Ins: []planInner{{3, 4}, {5, -6}},
+		A:   [3]int16{7, -8, 9},
+		AA:  [2][2]uint8{{1, 2}, {3, 4}},
+		Bs:  []byte("bytes"),
+		Bo:  []bool{true, true, false, false, true, false, true, true, true},
+		C:   complex(1.5, -2),
+		F:   3.25,
+		P:   &planInner{10, 11},
+		M:   map[string]uint8{"a": 1},
+		Pt:  point{-1, 2},
+		Pts: []point{{3, 4}},
+	}
+	b, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := Sizeof(x); s != len(b) {
+		t.Errorf("Sizeof: have %d, want %d", s, len(b))
+	}
+	if c, err := Encode(x, nil); err != nil || !bytes.Equal(c, b) { //not addressable
+		t.Errorf("Encode value: have %v, %v, want %v", c, err, b)
+	}
+	var y planNested
+	if err := Decode(b, &y); err != nil || !reflect.DeepEqual(y, x) {
+		t.Errorf("Decode: have %+v, %v", y, err)
+	}
+
+	var w bytes.Buffer
+	encoder := NewStreamEncoder(&w, DefaultEndian)
+	for i := 0; i < 3; i++ {
+		if err := encoder.Value(&x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewStreamDecoder(&w)
+	for i := 0; i < 3; i++ {
+		var z planNested
+		if err := decoder.Value(&z); err != nil || !reflect.DeepEqual(z, x) {
+			t.Fatalf("stream Decoder %d: have %+v, %v", i, z, err)
+		}
+	}
+
+	for i := 0; i < len(b); i++ {
+		var z planNested
+		if err := Decode(b[:i], &z); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("Decode(%d bytes): have %v, want io.ErrUnexpectedEOF", i, err)
+		}
+	}
+}
+
+func TestPlanSkip(t *testing.T) {
+	type from struct {
+		S []planInner
+		T uint8
+	}
+	type to struct {
+		S [1]planInner
+		T uint8
+	}
+	x := from{S: []planInner{{1, 2}, {3, 4}, {5, 6}}, T: 7}
+	b, err := Encode(x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var y to
+	if err := Decode(b, &y); err != nil || y.S[0] != x.S[0] || y.T != x.T {
+		t.Errorf("Decode: have %+v, %v", y, err)
+	}
+}
+
+func TestPlanRegStruct(t *testing.T) {
+	x := planPacked{X: 300, Y: -1, Z: []uint16{1}}
+	if s := Sizeof(x); s != 6 { //packed tag works for structs registed automatically too
+		t.Fatalf("Sizeof unregisted: have %d, want %d", s, 6)
+	}
+	if err := RegStruct((*planPacked)(nil)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Encode(x, nil)
+	if err != nil || !bytes.Equal(b, []byte{0xac, 0x02, 0x01, 0x01, 0x01, 0x00}) {
+		t.Errorf("Encode registed: have %v, %v", b, err)
+	}
+	if s := Sizeof(x); s != len(b) {
+		t.Errorf("Sizeof registed: have %d, want %d", s, len(b))
+	}
+	var y planPacked
+	if err := Decode(b, &y); err != nil || !reflect.DeepEqual(y, x) {
+		t.Errorf("Decode registed: have %+v, %v", y, err)
+	}
+}
@@ -0,0 +1,93 @@
+// pools of Encoders and Decoders, to encode/decode without allocation.
+
+package binary
+
+import (
+	"sync"
+)
+
+const (
+	minPoolSize = 64 //buffer size of the smallest class of pooled Encoders
+	poolClasses = 15 //the largest class is 1MB, larger Encoders are not pooled
+)
+
+var (
+	encoderPools [poolClasses]sync.Pool //Encoders of each class, see poolClass
+	decoderPool  sync.Pool
+)
+
+// poolClass returns the smallest class whose buffer can hold size bytes,
+// the buffer size of class c is minPoolSize<<c.
+// It returns poolClasses if size is larger than the largest class.
+func poolClass(size int) int {
+	c := 0
+	for n := minPoolSize; n < size && c < poolClasses; n <<= 1 {
+		c++
+	}
+	return c
+}
+
+// AcquireEncoder returns an Encoder from pool, whose buffer can hold at least size bytes.
+// It is in auto grow mode with DefaultEndian, as NewEncoderAutoGrow.
+// Call ReleaseEncoder to put it back after the encoded bytes are not used.
+func AcquireEncoder(size int) *Encoder {
+	var p *Encoder
+	c := poolClass(size)
+	if c < poolClasses {
+		for i := c; i < c+2 && i < poolClasses && p == nil; i++ { //buffer grown by encoding is released to a larger class
+			p, _ = encoderPools[i].Get().(*Encoder)
+		}
+		size = minPoolSize << c
+	}
+	if p == nil {
+		p = NewEncoderAutoGrow(size)
+	}
+	p.pooled = true
+	return p
+}
+
+// ReleaseEncoder put encoder back to pool for AcquireEncoder.
+// Neither encoder nor it's buffer can be used after that.
+// Encoders that are not from AcquireEncoder, or have been released, are ignored.
+func ReleaseEncoder(encoder *Encoder) {
+	if !encoder.pooled {
+		return
+	}
+	encoder.pooled = false
+	buff := encoder.buff[:cap(encoder.buff)]
+	if len(buff) < minPoolSize || len(buff) >= minPoolSize<<poolClasses {
+		return //too small or large to pool
+	}
+	c := poolClass(len(buff)+1) - 1 //the largest class that buff can hold
+	bools := encoder.bools[:0]
+	*encoder = Encoder{} //drop references to writer and map keys
+	encoder.buff, encoder.bools = buff, bools
+	encoder.endian = DefaultEndian
+	encoder.autoGrow = true
+	encoderPools[c].Put(encoder)
+}
+
+// AcquireDecoder returns a Decoder from pool, that decodes buffer with DefaultEndian,
+// as NewDecoder.
+// Call ReleaseDecoder to put it back after decoding.
+func AcquireDecoder(buffer []byte) *Decoder {
+	p, _ := decoderPool.Get().(*Decoder)
+	if p == nil {
+		p = &Decoder{}
+	}
+	p.Init(buffer, DefaultEndian)
+	p.pooled = true
+	return p
+}
+
+// ReleaseDecoder put decoder back to pool for AcquireDecoder.
+// Decoder can not be used after that, but values decoded by it are still valid,
+// even in zero-copy mode, as long as the buffer is not modified.
+// Decoders that are not from AcquireDecoder, or have been released, are ignored.
+func ReleaseDecoder(decoder *Decoder) {
+	if !decoder.pooled {
+		return
+	}
+	*decoder = Decoder{} //drop references to buffer and reader
+	decoderPool.Put(decoder)
+}
@@ -0,0 +1,151 @@
+package binary
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// poolClassesSize is the buffer size of the largest class.
+const poolClassesSize = minPoolSize << (poolClasses - 1)
+
+func TestPoolClass(t *testing.T) {
+	cases := []struct {
+		size, class int
+	}{
+		{0, 0},
+		{64, 0},
+		{65, 1},
+		{128, 1},
+		{4096, 6},
+		{1 << 20, 14},
+		{1<<20 + 1, poolClasses},
+	}
+	for _, c := range cases {
+		if have := poolClass(c.size); have != c.class {
+			t.Errorf("poolClass(%d): have %d, want %d", c.size, have, c.class)
+		}
+	}
+}
+
+func TestPoolEncoder(t *testing.T) {
+	x := planNested{N: 1, S: "pool", Bs: []byte("bytes")}
+	want, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		encoder := AcquireEncoder(100)
+		if encoder.Len() != 0 || encoder.Cap() < 100 || !encoder.autoGrow || encoder.endian != DefaultEndian {
+			t.Fatalf("AcquireEncoder: have len %d cap %d, autoGrow %v, %v", encoder.Len(), encoder.Cap(), encoder.autoGrow, encoder.endian)
+		}
+		if err := encoder.Value(&x); err != nil || !bytes.Equal(encoder.Buffer(), want) {
+			t.Errorf("Encoder.Value: have %v, %v, want %v", encoder.Buffer(), err, want)
+		}
+		encoder.setEndian(BigEndian) //dropped by ReleaseEncoder
+		encoder.SetCanonical(true)
+		ReleaseEncoder(encoder)
+	}
+
+	large := AcquireEncoder(poolClassesSize + 1) //not pooled
+	if large.Cap() != poolClassesSize+1 {
+		t.Errorf("AcquireEncoder large: have cap %d, want %d", large.Cap(), poolClassesSize+1)
+	}
+	ReleaseEncoder(large)
+
+	buff := make([]byte, 100) //not from AcquireEncoder, ignored
+	foreign := NewEncoderBuffer(buff)
+	foreign.Uint8(1)
+	ReleaseEncoder(foreign)
+	if foreign.Len() != 1 || &foreign.buff[0] != &buff[0] {
+		t.Errorf("ReleaseEncoder foreign: have len %d, it is released", foreign.Len())
+	}
+
+	encoder := AcquireEncoder(0)
+	ReleaseEncoder(encoder)
+	ReleaseEncoder(encoder) //released twice, ignored
+	if a, b := AcquireEncoder(0), AcquireEncoder(0); a == b {
+		t.Errorf("AcquireEncoder: have the same Encoder twice")
+	}
+}
+
+type poolFixed struct {
+	A uint32
+	B int16
+	C [4]uint8
+	D float64
+	E bool
+}
+
+func TestPoolAllocs(t *testing.T) {
+	x := poolFixed{A: 1, B: -2, C: [4]uint8{3}, D: 4.5, E: true}
+	b, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := testing.AllocsPerRun(100, func() {
+		encoder := AcquireEncoder(0)
+		encoder.Value(&x)
+		ReleaseEncoder(encoder)
+	}); n != 0 {
+		t.Errorf("AcquireEncoder/Value/ReleaseEncoder: have %v allocs, want 0", n)
+	}
+	var y poolFixed
+	if n := testing.AllocsPerRun(100, func() {
+		decoder := AcquireDecoder(b)
+		decoder.Value(&y)
+		ReleaseDecoder(decoder)
+	}); n != 0 {
+		t.Errorf("AcquireDecoder/Value/ReleaseDecoder: have %v allocs, want 0", n)
+	}
+	if y != x {
+		t.Errorf("Decoder.Value: have %+v, want %+v", y, x)
+	}
+}
+
+func TestPoolDecoder(t *testing.T) {
+	x := planFixed{A: 1, B: 2, C: [2]uint16{3, 4}, D: true, E: []bool{true}, F: []byte("bytes"), G: 5}
+	b, err := Encode(&x, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		decoder := AcquireDecoder(b)
+		var y planFixed
+		if err := decoder.Value(&y); err != nil || !reflect.DeepEqual(y, x) {
+			t.Errorf("Decoder.Value: have %+v, %v", y, err)
+		}
+		decoder.SetZeroCopy(true) //dropped by ReleaseDecoder
+		decoder.SetLimits(Limits{MaxBytes: 1})
+		ReleaseDecoder(decoder)
+	}
+	decoder := AcquireDecoder(b)
+	if decoder.zeroCopy || decoder.limits != DefaultLimits {
+		t.Errorf("AcquireDecoder: have zeroCopy %v, limits %+v", decoder.zeroCopy, decoder.limits)
+	}
+	ReleaseDecoder(decoder)
+	ReleaseDecoder(decoder) //released twice, ignored
+	if a, b := AcquireDecoder(nil), AcquireDecoder(nil); a == b {
+		t.Errorf("AcquireDecoder: have the same Decoder twice")
+	}
+
+	foreign := NewDecoder(b) //not from AcquireDecoder, ignored
+	ReleaseDecoder(foreign)
+	if foreign.Cap() != len(b) {
+		t.Errorf("ReleaseDecoder foreign: have cap %d, it is released", foreign.Cap())
+	}
+}
+
+func TestResetZero(t *testing.T) {
+	encoder := NewEncoder(8)
+	encoder.Uint32(0x01020304, false)
+	encoder.Reset() //bytes are not changed
+	if encoder.Len() != 0 || !bytes.Equal(encoder.buff[:4], []byte{4, 3, 2, 1}) {
+		t.Errorf("Reset: have len %d, %v", encoder.Len(), encoder.buff[:4])
+	}
+	encoder.Uint16(0x0506, false)
+	encoder.ResetZero()
+	if encoder.Len() != 0 || !bytes.Equal(encoder.buff[:4], []byte{0, 0, 2, 1}) {
+		t.Errorf("ResetZero: have len %d, %v", encoder.Len(), encoder.buff[:4])
+	}
+}
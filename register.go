@@ -0,0 +1,138 @@
+// regist concrete types to encode/decode interface values.
+
+package binary
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// RegisterName regist the concrete type of value with name,
+// to encode/decode interface values that holding this type.
+// Interface value of this type will be encoded as name followed by it's value.
+// Regist by a nil pointer is aviable for pointer types.
+// RegisterName("pkg.Event", (*Event)(nil)) is recommended usage.
+func RegisterName(name string, value interface{}) error {
+	return _ifaceTypeMgr.regist(reflect.TypeOf(value), name, 0, false)
+}
+
+// RegisterID regist the concrete type of value with id,
+// to encode/decode interface values that holding this type.
+// Interface value of this type will be encoded as uvarint id followed by it's value,
+// which is more compact than RegisterName.
+// If a type is registed by both name and id, it will be encoded with id.
+func RegisterID(id uint32, value interface{}) error {
+	return _ifaceTypeMgr.regist(reflect.TypeOf(value), "", id, true)
+}
+
+// type tag of interface values
+const (
+	ifaceNil    = 0 //nil interface
+	ifaceName   = 1 //type name string follows
+	ifaceIDBase = 2 //type id + ifaceIDBase
+)
+
+var _ifaceTypeMgr = ifaceTypeMgr{
+	byName: make(map[string]reflect.Type),
+	byID:   make(map[uint32]reflect.Type),
+	byType: make(map[reflect.Type]*ifaceType),
+}
+
+type ifaceTypeMgr struct {
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byID   map[uint32]reflect.Type
+	byType map[reflect.Type]*ifaceType
+}
+
+//informatin of a concrete type registed for interface values
+type ifaceType struct {
+	name  string
+	id    uint32
+	hasID bool
+}
+
+func (mgr *ifaceTypeMgr) regist(t reflect.Type, name string, id uint32, hasID bool) error {
+	if t == nil || !validUserType(t) {
+		return fmt.Errorf("binary: unsupported type %v for interface regist", t)
+	}
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	info := mgr.byType[t]
+	if info == nil {
+		info = &ifaceType{}
+	}
+	if hasID {
+		if _t, ok := mgr.byID[id]; ok && _t != t {
+			return fmt.Errorf("binary: regist duplicate id %d for %s and %s", id, _t.String(), t.String())
+		}
+		if info.hasID && info.id != id {
+			return fmt.Errorf("binary: regist type %s with id %d and %d", t.String(), info.id, id)
+		}
+		info.id, info.hasID = id, true
+		mgr.byID[id] = t
+	} else {
+		if _t, ok := mgr.byName[name]; ok && _t != t {
+			return fmt.Errorf("binary: regist duplicate name %q for %s and %s", name, _t.String(), t.String())
+		}
+		if info.name != "" && info.name != name {
+			return fmt.Errorf("binary: regist type %s with name %q and %q", t.String(), info.name, name)
+		}
+		info.name = name
+		mgr.byName[name] = t
+	}
+	mgr.byType[t] = info
+	return nil
+}
+
+func (mgr *ifaceTypeMgr) query(t reflect.Type) *ifaceType {
+	mgr.mu.RLock()
+	info := mgr.byType[t]
+	mgr.mu.RUnlock()
+	return info
+}
+
+func (mgr *ifaceTypeMgr) queryName(name string) reflect.Type {
+	mgr.mu.RLock()
+	t := mgr.byName[name]
+	mgr.mu.RUnlock()
+	return t
+}
+
+func (mgr *ifaceTypeMgr) queryID(id uint64) reflect.Type {
+	if id > math.MaxUint32 {
+		return nil
+	}
+	mgr.mu.RLock()
+	t := mgr.byID[uint32(id)]
+	mgr.mu.RUnlock()
+	return t
+}
+
+// bits of the type tag of interface value
+func (info *ifaceType) bits() int {
+	if info.hasID {
+		return SizeofUvarint(uint64(info.id)+ifaceIDBase) * 8
+	}
+	return (SizeofUvarint(ifaceName) + sizeofString(len(info.name))) * 8
+}
+
+// bits of interface value v, or -1 if the concrete type is not registed
+func bitsOfInterface(v reflect.Value) int {
+	if v.IsNil() {
+		return SizeofUvarint(ifaceNil) * 8
+	}
+	e := v.Elem()
+	info := _ifaceTypeMgr.query(e.Type())
+	if info == nil {
+		return -1
+	}
+	s := bitsOfElem(e, customOf(e.Type()), false)
+	if s < 0 {
+		return -1
+	}
+	return info.bits() + s
+}
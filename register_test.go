@@ -0,0 +1,189 @@
+package binary
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testEvent interface {
+	EventName() string
+}
+
+type testEventCreated struct {
+	ID   uint32
+	Name string
+	Ok   bool
+}
+
+func (e testEventCreated) EventName() string { return "created" }
+
+type testEventDeleted struct {
+	ID     uint32
+	Reason *string
+}
+
+func (e *testEventDeleted) EventName() string { return "deleted" }
+
+type testEventHolder struct {
+	Flag  bool
+	Body  testEvent
+	Bodys []testEvent
+	Any   interface{}
+	Map   map[string]interface{}
+	PBody *testEvent
+	Last  bool
+}
+
+func init() {
+	if err := RegisterName("binary.testEventCreated", testEventCreated{}); err != nil {
+		panic(err)
+	}
+	if err := RegisterID(1, (*testEventDeleted)(nil)); err != nil {
+		panic(err)
+	}
+	if err := RegisterID(2, uint16(0)); err != nil {
+		panic(err)
+	}
+	if err := RegisterName("string", ""); err != nil {
+		panic(err)
+	}
+}
+
+func TestInterface(t *testing.T) {
+	reason := "expired"
+	var body testEvent = &testEventDeleted{ID: 3}
+	var data = [3]testEventHolder{
+		{
+			Flag:  true,
+			Body:  testEventCreated{ID: 1, Name: "a", Ok: true},
+			Bodys: []testEvent{&testEventDeleted{ID: 2, Reason: &reason}, nil, testEventCreated{ID: 5}},
+			Any:   uint16(0x1234),
+			Map:   map[string]interface{}{"a": "b", "c": uint16(4), "nil": nil},
+			PBody: &body,
+			Last:  true,
+		},
+		{
+			Any: testEventCreated{Name: "any"},
+			Map: map[string]interface{}{}, //nil map will be decoded as empty map
+		},
+		{
+			Body: &testEventDeleted{ID: 9},
+			Map:  map[string]interface{}{},
+			Last: true,
+		},
+	}
+
+	b, err := Encode(&data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := Sizeof(&data); size != len(b) {
+		t.Errorf("Interface got size %d, need %d", len(b), size)
+	}
+	var r [3]testEventHolder
+	if err := Decode(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, data) {
+		t.Errorf("Interface got %#v\nneed %#v\n", r, data)
+	}
+
+	var r2 [1]testEventHolder //skip the others
+	if err := Decode(b, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r2[0], data[0]) {
+		t.Errorf("Interface got %#v\nneed %#v\n", r2[0], data[0])
+	}
+
+	var top testEvent = testEventCreated{ID: 7}
+	b, err = Encode(&top, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rtop testEvent
+	if err := Decode(b, &rtop); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rtop, top) {
+		t.Errorf("Interface got %#v\nneed %#v\n", rtop, top)
+	}
+}
+
+func TestInterfaceSkip(t *testing.T) {
+	reason := "expired"
+	var body testEvent = testEventCreated{ID: 1, Name: "a"}
+	cases := []interface{}{
+		&body,
+		[]interface{}{uint16(1), "abc", nil, testEventCreated{ID: 1, Name: "a"}},
+		testEventHolder{
+			Body:  &testEventDeleted{ID: 2, Reason: &reason},
+			Bodys: []testEvent{testEventCreated{ID: 3}, nil},
+			Any:   "any",
+			Map:   map[string]interface{}{"a": uint16(4)},
+			Last:  true,
+		},
+	}
+	for _, c := range cases {
+		b, err := Encode(c, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ := reflect.TypeOf(c)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		decoder := NewDecoder(b)
+		if n := decoder.skipByType(typ, false); n != len(b) || decoder.Consumed() != int64(len(b)) {
+			t.Errorf("skip %T: have size %d, consumed %d, want %d", c, n, decoder.Consumed(), len(b))
+		}
+	}
+}
+
+func TestInterfaceError(t *testing.T) {
+	type unregistered struct{ A int }
+	var data testEventHolder
+	data.Any = unregistered{}
+	if _, err := Encode(&data, nil); err == nil {
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+	if size := Sizeof(&data); size >= 0 {
+		t.Errorf("InterfaceError: have size %d, want -1", size)
+	}
+
+	data.Any = uint16(1)
+	b, err := Encode(&data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r struct {
+		Flag  bool
+		Body  testEvent
+		Bodys []testEvent
+		Any   testEvent //uint16 is not a testEvent
+	}
+	if err := Decode(b, &r); err == nil {
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+
+	var any interface{}
+	if err := Decode([]byte{ifaceIDBase + 100}, &any); err == nil {
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+	if err := Decode([]byte{ifaceName, 1, 'x'}, &any); err == nil {
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+
+	if err := RegisterID(1, testEventCreated{}); err == nil { //duplicate id
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+	if err := RegisterName("other", testEventCreated{}); err == nil { //duplicate type
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+	if err := RegisterName("binary.testEventCreated", testEventCreated{}); err != nil { //same regist
+		t.Error(err)
+	}
+	if err := RegisterName("uintptr", uintptr(0)); err == nil { //unsupported type
+		t.Errorf("InterfaceError: have err == nil, want non-nil")
+	}
+}
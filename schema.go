@@ -0,0 +1,452 @@
+// describe the encoding of go types, for self-describing data.
+
+package binary
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Schema describes how values of a type are encoded.
+// It is embedded in self-describing data by EncodeDescribed,
+// so that DecodeDescribed can decode the data without the original type.
+// Schema of recursive types is recursive.
+type Schema struct {
+	Kind      reflect.Kind  // kind of the type
+	Name      string        // package path and name of named types, eg: time.Time
+	Codec     Codec         // special encoding of the type, eg: CodecTime
+	Packed    bool          // ints are encoded as varint/uvarint
+	Versioned bool          // struct fields are encoded with id and length
+	Len       int           // length of Array
+	Key       *Schema       // key of Map
+	Elem      *Schema       // element of Array, Slice, Map and Ptr
+	Fields    []SchemaField // encoded fields of Struct
+	Types     []SchemaType  // registed concrete types of Interface
+
+	bits int //min number of bits to encode a value, see initBits
+}
+
+// SchemaField is a field of struct Schema.
+type SchemaField struct {
+	Name string
+	ID   uint64 // field id of versioned struct
+	Type *Schema
+}
+
+// SchemaType is a concrete type that interface values may hold,
+// see RegisterName and RegisterID.
+type SchemaType struct {
+	Name  string // registed name
+	ID    uint32 // registed id, it is used if HasID
+	HasID bool
+	Type  *Schema
+}
+
+// Codec is the special encoding of a type that is not encoded by it's kind.
+type Codec uint8
+
+const (
+	CodecNone   Codec = iota // encoded by kind
+	CodecTime                // time.Time, see stdtypes.go
+	CodecBigInt              // math/big.Int, sign bit and length-prefixed bytes of abs value
+	CodecBytes               // length-prefixed bytes, eg: BinarySerializer and encoding.BinaryMarshaler
+)
+
+// SchemaOf returns Schema of type t.
+// It returns *UnsupportedTypeError if t can not be encoded,
+// and *TagError if t has struct fields with length tags, see length.go.
+func SchemaOf(t reflect.Type) (s *Schema, err error) {
+	if t == nil || !validUserType(t) {
+		return nil, &UnsupportedTypeError{Type: t, op: "binary.SchemaOf"}
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			s, err = nil, e.(error)
+		}
+	}()
+	b := schemaBuilder{types: make(map[schemaKey]*Schema)}
+	s = b.schema(t, false)
+	done := make(map[*Schema]bool)
+	for _, x := range b.types {
+		x.initBits(done)
+	}
+	return s, nil
+}
+
+type schemaKey struct {
+	t      reflect.Type
+	packed bool
+}
+
+// schemaBuilder build Schema of go types, Schema of a type is built only once.
+type schemaBuilder struct {
+	types map[schemaKey]*Schema
+}
+
+func (b *schemaBuilder) schema(t reflect.Type, packed bool) *Schema {
+	key := schemaKey{t, packed && packedIntsType(t) > 0}
+	if s := b.types[key]; s != nil {
+		return s
+	}
+	s := &Schema{Kind: t.Kind(), Packed: key.packed}
+	if t.PkgPath() != "" {
+		s.Name = t.PkgPath() + "." + t.Name()
+	}
+	b.types[key] = s //before elements, to stop recursive types
+
+	if std := stdTypeOf(t); std != nil {
+		switch std.kind {
+		case stdTime:
+			s.Codec = CodecTime
+		case stdDuration:
+			s.Packed = true
+		case stdBigInt:
+			s.Codec = CodecBigInt
+		default:
+			s.Codec = CodecBytes
+		}
+		return s
+	}
+	if t.Kind() != reflect.Ptr && customOf(t) != customNone {
+		s.Codec = CodecBytes
+		return s
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Uint, reflect.String,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+	case reflect.Array:
+		s.Len = t.Len()
+		s.Elem = b.schema(t.Elem(), packed)
+	case reflect.Slice:
+		s.Elem = b.schema(t.Elem(), packed)
+	case reflect.Map:
+		s.Key = b.schema(t.Key(), packed)
+		s.Elem = b.schema(t.Elem(), packed)
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Ptr {
+			panic(&UnsupportedTypeError{Type: t, op: "binary.SchemaOf"})
+		}
+		s.Elem = b.schema(t.Elem(), packed)
+	case reflect.Struct:
+		info := queryStruct(t)
+		if info == nil {
+			panic(&UnsupportedTypeError{Type: t, op: "binary.SchemaOf"})
+		}
+		if info.err != nil {
+			panic(info.err)
+		}
+		s.Versioned = info.versioned
+		for _, f := range info.fields {
+			if f.hasLength() {
+				panic(&TagError{Type: t, Field: f.field.Name, Err: errors.New("length tag is not supported by Schema")})
+			}
+			if f.bits.n > 0 {
+				panic(&TagError{Type: t, Field: f.field.Name, Err: errors.New("bit field is not supported by Schema")})
+			}
+			if f.endian != nil {
+				panic(&TagError{Type: t, Field: f.field.Name, Err: errors.New("byte order tag is not supported by Schema")})
+			}
+			if !f.ignore {
+				s.Fields = append(s.Fields, SchemaField{Name: f.field.Name, ID: f.id, Type: b.schema(f.field.Type, f.packed)})
+			}
+		}
+	case reflect.Interface:
+		for _, c := range _ifaceTypeMgr.types() {
+			s.Types = append(s.Types, SchemaType{Name: c.name, ID: c.id, HasID: c.hasID, Type: b.schema(c.t, false)})
+		}
+	default:
+		panic(&UnsupportedTypeError{Type: t, op: "binary.SchemaOf"})
+	}
+	return s
+}
+
+// types returns all registed concrete types sorted by name and id.
+func (mgr *ifaceTypeMgr) types() []ifaceConcrete {
+	mgr.mu.RLock()
+	types := make([]ifaceConcrete, 0, len(mgr.byType))
+	for t, info := range mgr.byType {
+		types = append(types, ifaceConcrete{*info, t})
+	}
+	mgr.mu.RUnlock()
+	sort.Slice(types, func(i, j int) bool {
+		if a, b := types[i], types[j]; a.name != b.name {
+			return a.name < b.name
+		}
+		return types[i].id < types[j].id
+	})
+	return types
+}
+
+type ifaceConcrete struct {
+	ifaceType
+	t reflect.Type
+}
+
+// schemaMagic begins self-describing data, the last byte is version of schema encoding.
+const schemaMagic = "BDS\x01"
+
+// wire code of kinds, it is index of this table.
+// Never change the order, append new kinds only.
+var schemaKinds = [...]reflect.Kind{
+	reflect.Invalid, reflect.Bool, reflect.Int, reflect.Uint, reflect.String,
+	reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+	reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+	reflect.Array, reflect.Slice, reflect.Map, reflect.Ptr, reflect.Struct, reflect.Interface,
+}
+
+func schemaKindCode(k reflect.Kind) uint8 {
+	for i, kind := range schemaKinds {
+		if kind == k {
+			return uint8(i)
+		}
+	}
+	return 0
+}
+
+// flags of schema encoding
+const (
+	schemaPacked    = 1 << iota //Schema.Packed
+	schemaVersioned             //Schema.Versioned
+)
+
+// schema encode s as a table of types, s is the first one.
+// Every type is encoded as kind, name, codec, flags and kind specific fields,
+// referenced types are encoded as index of the table.
+func (encoder *Encoder) schema(s *Schema) {
+	index := make(map[*Schema]int)
+	var table []*Schema
+	var add func(s *Schema)
+	add = func(s *Schema) {
+		if _, ok := index[s]; ok {
+			return
+		}
+		index[s] = len(table)
+		table = append(table, s)
+		for _, e := range s.refs() {
+			add(e)
+		}
+	}
+	add(s)
+
+	copy(encoder.reserve(len(schemaMagic)), schemaMagic)
+	encoder.Uvarint(uint64(len(table)))
+	for _, s := range table {
+		flags := uint8(0)
+		if s.Packed {
+			flags |= schemaPacked
+		}
+		if s.Versioned {
+			flags |= schemaVersioned
+		}
+		encoder.Uint8(schemaKindCode(s.Kind))
+		encoder.String(s.Name)
+		encoder.Uint8(uint8(s.Codec))
+		encoder.Uint8(flags)
+		if s.Codec != CodecNone {
+			continue
+		}
+		switch s.Kind {
+		case reflect.Array:
+			encoder.Uvarint(uint64(s.Len))
+			encoder.Uvarint(uint64(index[s.Elem]))
+		case reflect.Slice, reflect.Ptr:
+			encoder.Uvarint(uint64(index[s.Elem]))
+		case reflect.Map:
+			encoder.Uvarint(uint64(index[s.Key]))
+			encoder.Uvarint(uint64(index[s.Elem]))
+		case reflect.Struct:
+			encoder.Uvarint(uint64(len(s.Fields)))
+			for _, f := range s.Fields {
+				encoder.String(f.Name)
+				encoder.Uvarint(f.ID)
+				encoder.Uvarint(uint64(index[f.Type]))
+			}
+		case reflect.Interface:
+			encoder.Uvarint(uint64(len(s.Types)))
+			for _, c := range s.Types {
+				encoder.String(c.Name)
+				encoder.Uint8(boolByte(c.HasID))
+				encoder.Uvarint(uint64(c.ID))
+				encoder.Uvarint(uint64(index[c.Type]))
+			}
+		}
+	}
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// refs returns the types that s references.
+func (s *Schema) refs() []*Schema {
+	if s.Codec != CodecNone {
+		return nil
+	}
+	var refs []*Schema
+	if s.Key != nil {
+		refs = append(refs, s.Key)
+	}
+	if s.Elem != nil {
+		refs = append(refs, s.Elem)
+	}
+	for _, f := range s.Fields {
+		refs = append(refs, f.Type)
+	}
+	for _, c := range s.Types {
+		refs = append(refs, c.Type)
+	}
+	return refs
+}
+
+// errBadSchema is returned for invalid schema encoding.
+var errBadSchema = errors.New("binary.Decoder.Value: invalid schema")
+
+// schema decode Schema encoded by Encoder.schema.
+// The decoded Schema is checked, so that decoding with it always makes progress.
+func (decoder *Decoder) schema() *Schema {
+	magic := decoder.reserve(len(schemaMagic))
+	if last := len(schemaMagic) - 1; string(magic[:last]) != schemaMagic[:last] {
+		panic(fmt.Errorf("binary.Decoder.Value: missing schema, got magic %q", magic))
+	} else if magic[last] != schemaMagic[last] {
+		panic(fmt.Errorf("binary.Decoder.Value: unsupported schema version %d", magic[last]))
+	}
+
+	n := decoder.SliceLen(4 * 8) //kind, name, codec and flags
+	table := make([]*Schema, n)
+	for i := range table {
+		table[i] = &Schema{}
+	}
+	ref := func() *Schema {
+		i, _ := decoder.Uvarint()
+		if i >= uint64(n) {
+			panic(errBadSchema)
+		}
+		return table[i]
+	}
+	for _, s := range table {
+		code := decoder.Uint8()
+		s.Name = decoder.String()
+		s.Codec = Codec(decoder.Uint8())
+		flags := decoder.Uint8()
+		s.Packed, s.Versioned = flags&schemaPacked != 0, flags&schemaVersioned != 0
+		if int(code) >= len(schemaKinds) || code == 0 && s.Codec == CodecNone || s.Codec > CodecBytes {
+			panic(fmt.Errorf("binary.Decoder.Value: invalid kind %d or codec %d in schema", code, s.Codec))
+		}
+		s.Kind = schemaKinds[code]
+		if s.Codec != CodecNone {
+			continue
+		}
+		switch s.Kind {
+		case reflect.Array:
+			l, _ := decoder.Uvarint()
+			if l > uint64(maxInt) {
+				panic(errBadSchema)
+			}
+			s.Len, s.Elem = int(l), ref()
+		case reflect.Slice, reflect.Ptr:
+			s.Elem = ref()
+		case reflect.Map:
+			s.Key, s.Elem = ref(), ref()
+		case reflect.Struct:
+			s.Fields = make([]SchemaField, decoder.SliceLen(3*8))
+			for i := range s.Fields {
+				f := &s.Fields[i]
+				f.Name = decoder.String()
+				f.ID, _ = decoder.Uvarint()
+				f.Type = ref()
+			}
+		case reflect.Interface:
+			s.Types = make([]SchemaType, decoder.SliceLen(4*8))
+			for i := range s.Types {
+				c := &s.Types[i]
+				c.Name = decoder.String()
+				c.HasID = decoder.Uint8() != 0
+				id, _ := decoder.Uvarint()
+				c.ID = uint32(id)
+				c.Type = ref()
+			}
+		}
+	}
+	if n == 0 {
+		panic(errBadSchema)
+	}
+	for _, s := range table {
+		if s.Kind == reflect.Ptr && s.Codec == CodecNone && s.Elem.Kind == reflect.Ptr && s.Elem.Codec == CodecNone {
+			panic(errBadSchema)
+		}
+	}
+	done := make(map[*Schema]bool)
+	for _, s := range table {
+		s.initBits(done)
+	}
+	return table[0]
+}
+
+// initBits set the minimum number of bits to encode a value of s, it works like minBitsOf.
+// It panics if s has recursive struct fields that consume no bytes,
+// which can not be declared in go but may be in hostile input.
+// done[s] is false while s is being initialized.
+func (s *Schema) initBits(done map[*Schema]bool) {
+	if ok, found := done[s]; found {
+		if !ok {
+			panic(errBadSchema)
+		}
+		return
+	}
+	done[s] = false
+	s.bits = 8 //varint, length or type tag
+	switch s.Codec {
+	case CodecTime:
+		s.bits = stdTypes[tTime].minBits
+	case CodecBigInt:
+		s.bits = 1 + 8
+	case CodecNone:
+		switch s.Kind {
+		case reflect.Bool, reflect.Ptr:
+			s.bits = 1
+		case reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32,
+			reflect.Int64, reflect.Uint64:
+			if !s.Packed {
+				s.bits = int(kindSize(s.Kind)) * 8
+			}
+		case reflect.Int8, reflect.Uint8, reflect.Float32, reflect.Float64,
+			reflect.Complex64, reflect.Complex128:
+			s.bits = int(kindSize(s.Kind)) * 8
+		case reflect.Struct:
+			if !s.Versioned {
+				s.bits = 0
+				for _, f := range s.Fields {
+					f.Type.initBits(done)
+					s.bits += f.Type.bits
+				}
+			}
+		}
+	}
+	done[s] = true
+}
+
+// kindSize returns size of fixed size number kind k.
+func kindSize(k reflect.Kind) uintptr {
+	switch k {
+	case reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Complex64:
+		return 8
+	case reflect.Complex128:
+		return 16
+	}
+	return 0
+}
@@ -0,0 +1,247 @@
+// std compatible mode, byte-identical to encoding/binary.
+
+package binary
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// In std compatible mode, data is encoded exactly as encoding/binary:
+//
+//	only fixed-size values and slices of fixed-size values are supported,
+//	int, uint, string, map, pointer(except the top level) and interface are not
+//	bool is encoded as 1 byte, 1 for true and 0 for false, any non-zero byte is decoded as true
+//	top level slice is encoded without length
+//	struct fields are encoded in order without padding, field tags are ignored
+//	blank(_) fields are encoded as zeros and skipped when decoding
+//	BinarySerializer and encoding.BinaryMarshaler are not used
+//
+// So that it can replace encoding/binary without changing the encoded data.
+
+// SetStdCompatible enable/disable std compatible mode of Encoder.
+// See WriteStdCompatible.
+func (encoder *Encoder) SetStdCompatible(enable bool) {
+	encoder.stdCompatible = enable
+}
+
+// SetStdCompatible enable/disable std compatible mode of Decoder.
+// See ReadStdCompatible.
+func (decoder *Decoder) SetStdCompatible(enable bool) {
+	decoder.stdCompatible = enable
+}
+
+// SizeStdCompatible returns how many bytes WriteStdCompatible would generate
+// to encode the value v, which must be a fixed-size value or a slice of
+// fixed-size values, or a pointer to such data.
+// If v is neither of these, SizeStdCompatible returns -1.
+// It is the same as Size of encoding/binary.
+func SizeStdCompatible(v interface{}) int {
+	return sizeofStd(reflect.Indirect(reflect.ValueOf(v)))
+}
+
+// ReadStdCompatible reads data from r like Read, but the same as Read of encoding/binary.
+// Data must be a pointer to a fixed-size value or a slice of fixed-size values.
+//
+// The error is EOF only if no bytes were read.
+// If an EOF happens after reading some but not all the bytes,
+// ReadStdCompatible returns ErrUnexpectedEOF.
+func ReadStdCompatible(r io.Reader, endian Endian, data interface{}) error {
+	v := reflect.ValueOf(data)
+	size := -1
+	switch v.Kind() {
+	case reflect.Ptr:
+		size = sizeofStd(v.Elem())
+	case reflect.Slice:
+		size = sizeofStd(v)
+	}
+	if size < 0 {
+		return fmt.Errorf("binary.Read: invalid type %T", data)
+	}
+	var b [16]byte
+	bs := b[:]
+	if size > len(b) {
+		bs = make([]byte, size)
+	}
+	bs = bs[:size]
+	if _, err := io.ReadFull(r, bs); err != nil {
+		return err
+	}
+
+	var decoder Decoder
+	decoder.Init(bs, endian)
+	decoder.stdCompatible = true
+	return decoder.Value(data)
+}
+
+// WriteStdCompatible writes data to w like Write, but the same as Write of encoding/binary.
+// Data must be a fixed-size value or a slice of fixed-size values, or a pointer to such data.
+func WriteStdCompatible(w io.Writer, endian Endian, data interface{}) error {
+	size := SizeStdCompatible(data)
+	if size < 0 {
+		return fmt.Errorf("binary.Write: invalid type %T", data)
+	}
+	return write(w, endian, data, size, true)
+}
+
+// sizeofStd returns size of v in std compatible mode, or -1 if it is not supported.
+func sizeofStd(v reflect.Value) int {
+	if v.Kind() == reflect.Slice {
+		if s := sizeofStdType(v.Type().Elem()); s >= 0 {
+			return s * v.Len()
+		}
+		return -1
+	}
+	if !v.IsValid() {
+		return -1
+	}
+	return sizeofStdType(v.Type())
+}
+
+// sizeofStdType returns size of fixed-size type t, or -1 if it is not fixed-size.
+func sizeofStdType(t reflect.Type) int {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16,
+		reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return int(t.Size())
+	case reflect.Array:
+		if s := sizeofStdType(t.Elem()); s >= 0 {
+			return s * t.Len()
+		}
+	case reflect.Struct:
+		sum := 0
+		for i, n := 0, t.NumField(); i < n; i++ {
+			s := sizeofStdType(t.Field(i).Type)
+			if s < 0 {
+				return -1
+			}
+			sum += s
+		}
+		return sum
+	}
+	return -1
+}
+
+// stdValue encode x in std compatible mode.
+func (encoder *Encoder) stdValue(x interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(x))
+	if sizeofStd(v) < 0 {
+		return &UnsupportedTypeError{Type: reflect.TypeOf(x), op: "binary.Encoder.Value"}
+	}
+	encoder.stdElem(v)
+	return nil
+}
+
+func (encoder *Encoder) stdElem(v reflect.Value) {
+	switch k := v.Kind(); k {
+	case reflect.Bool:
+		encoder.Uint8(boolByte(v.Bool()))
+	case reflect.Int8:
+		encoder.Int8(int8(v.Int()))
+	case reflect.Int16:
+		encoder.Int16(int16(v.Int()), false)
+	case reflect.Int32:
+		encoder.Int32(int32(v.Int()), false)
+	case reflect.Int64:
+		encoder.Int64(v.Int(), false)
+	case reflect.Uint8:
+		encoder.Uint8(uint8(v.Uint()))
+	case reflect.Uint16:
+		encoder.Uint16(uint16(v.Uint()), false)
+	case reflect.Uint32:
+		encoder.Uint32(uint32(v.Uint()), false)
+	case reflect.Uint64:
+		encoder.Uint64(v.Uint(), false)
+	case reflect.Float32:
+		encoder.Float32(float32(v.Float()))
+	case reflect.Float64:
+		encoder.Float64(v.Float())
+	case reflect.Complex64:
+		encoder.Complex64(complex64(v.Complex()))
+	case reflect.Complex128:
+		encoder.Complex128(v.Complex())
+	case reflect.Slice, reflect.Array:
+		for i, n := 0, v.Len(); i < n; i++ {
+			encoder.stdElem(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i, n := 0, v.NumField(); i < n; i++ {
+			if t.Field(i).Name == "_" { //padding
+				b := encoder.reserve(sizeofStdType(t.Field(i).Type))
+				for j := range b {
+					b[j] = 0
+				}
+				continue
+			}
+			encoder.stdElem(v.Field(i))
+		}
+	}
+}
+
+// stdValue decode x in std compatible mode.
+func (decoder *Decoder) stdValue(x interface{}) error {
+	v := reflect.ValueOf(x)
+	switch v.Kind() {
+	case reflect.Ptr:
+		v = v.Elem()
+	case reflect.Slice:
+	default:
+		v = reflect.Value{}
+	}
+	if sizeofStd(v) < 0 {
+		return &UnsupportedTypeError{Type: reflect.TypeOf(x), op: "binary.Decoder.Value"}
+	}
+	decoder.stdElem(v)
+	return nil
+}
+
+func (decoder *Decoder) stdElem(v reflect.Value) {
+	switch k := v.Kind(); k {
+	case reflect.Bool:
+		v.SetBool(decoder.Uint8() != 0)
+	case reflect.Int8:
+		v.SetInt(int64(decoder.Int8()))
+	case reflect.Int16:
+		v.SetInt(int64(decoder.Int16(false)))
+	case reflect.Int32:
+		v.SetInt(int64(decoder.Int32(false)))
+	case reflect.Int64:
+		v.SetInt(decoder.Int64(false))
+	case reflect.Uint8:
+		v.SetUint(uint64(decoder.Uint8()))
+	case reflect.Uint16:
+		v.SetUint(uint64(decoder.Uint16(false)))
+	case reflect.Uint32:
+		v.SetUint(uint64(decoder.Uint32(false)))
+	case reflect.Uint64:
+		v.SetUint(decoder.Uint64(false))
+	case reflect.Float32:
+		v.SetFloat(float64(decoder.Float32()))
+	case reflect.Float64:
+		v.SetFloat(decoder.Float64())
+	case reflect.Complex64:
+		v.SetComplex(complex128(decoder.Complex64()))
+	case reflect.Complex128:
+		v.SetComplex(decoder.Complex128())
+	case reflect.Slice, reflect.Array:
+		for i, n := 0, v.Len(); i < n; i++ {
+			decoder.stdElem(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i, n := 0, v.NumField(); i < n; i++ {
+			f := t.Field(i)
+			switch {
+			case f.Name == "_": //padding
+				decoder.Skip(sizeofStdType(f.Type))
+			case f.PkgPath != "":
+				panic(fmt.Errorf("binary.Decoder.Value: unexported field %s of %s", f.Name, t.String()))
+			default:
+				decoder.stdElem(v.Field(i))
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package binary
+
+import (
+	"bytes"
+	std "encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type stdInner struct {
+	A int16
+	B [2]bool
+	_ [3]byte
+	C complex64
+}
+
+type stdNotFixed struct {
+	A int8
+	B []stdInner `binary:"ignore"` //tag is ignored
+}
+
+type stdFixed struct {
+	A bool
+	B int64
+	C [2]stdInner
+	_ int32
+	D uint32 `binary:"packed"`
+}
+
+func TestStdCompatible(t *testing.T) {
+	x := stdFixed{A: true, B: -2, C: [2]stdInner{{A: 1, B: [2]bool{true, false}, C: 3i}, {A: -1}}, D: 300}
+	bools := []bool{true, false, true}
+	datas := []interface{}{
+		true,
+		int8(-1),
+		uint16(0x1234),
+		int32(-5),
+		uint64(1 << 60),
+		float32(1.5),
+		-2.5,
+		complex64(1 + 2i),
+		complex128(3 - 4i),
+		[3]int16{1, -2, 3},
+		&x,
+		x,
+		[]stdFixed{x, {}},
+		bools,
+		[]float64{1, 2},
+		[]byte("abc"),
+		[]stdInner{},
+	}
+	for _, endian := range []Endian{LittleEndian, BigEndian} {
+		stdEndian := std.ByteOrder(std.LittleEndian)
+		if endian == Endian(BigEndian) {
+			stdEndian = std.BigEndian
+		}
+		for _, data := range datas {
+			var want, have bytes.Buffer
+			if err := std.Write(&want, stdEndian, data); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteStdCompatible(&have, endian, data); err != nil {
+				t.Fatalf("WriteStdCompatible %T: %v", data, err)
+			}
+			if !bytes.Equal(have.Bytes(), want.Bytes()) {
+				t.Errorf("WriteStdCompatible %T %s:\nhave %x\nwant %x", data, endian, have.Bytes(), want.Bytes())
+			}
+			if have, want := SizeStdCompatible(data), std.Size(data); have != want {
+				t.Errorf("SizeStdCompatible %T: have %d, want %d", data, have, want)
+			}
+
+			//decode to a new value
+			p, q := newStdValue(data), newStdValue(data)
+			if err := ReadStdCompatible(bytes.NewReader(want.Bytes()), endian, p.Interface()); err != nil {
+				t.Fatalf("ReadStdCompatible %T: %v", data, err)
+			}
+			if err := std.Read(bytes.NewReader(want.Bytes()), stdEndian, q.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p.Interface(), q.Interface()) {
+				t.Errorf("ReadStdCompatible %T:\nhave %+v\nwant %+v", data, p.Interface(), q.Interface())
+			}
+		}
+	}
+
+	//bools decode any non-zero byte as true
+	b := make([]bool, 2)
+	if err := ReadStdCompatible(bytes.NewReader([]byte{0, 2}), LittleEndian, b); err != nil || b[0] || !b[1] {
+		t.Errorf("ReadStdCompatible bools: have %v, %v", b, err)
+	}
+
+	//Encoder and Decoder in std compatible mode
+	encoder := NewEncoder(SizeStdCompatible(x))
+	encoder.SetStdCompatible(true)
+	if err := encoder.Value(&x); err != nil {
+		t.Fatal(err)
+	}
+	var y stdFixed
+	decoder := NewDecoder(encoder.Buffer())
+	decoder.SetStdCompatible(true)
+	if err := decoder.Value(&y); err != nil {
+		t.Fatal(err)
+	}
+	if y != x {
+		t.Errorf("Decoder.Value: have %+v, want %+v", y, x)
+	}
+}
+
+// newStdValue returns a slice of the same length of data, or a pointer to new value of data.
+func newStdValue(data interface{}) reflect.Value {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Slice {
+		return reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	}
+	return reflect.New(reflect.Indirect(v).Type())
+}
+
+func TestStdCompatibleError(t *testing.T) {
+	var s stdNotFixed
+	for _, data := range []interface{}{1, "s", &s, []string{"s"}, map[int8]int8{}, (*int8)(nil), nil} {
+		if n := SizeStdCompatible(data); n != -1 {
+			t.Errorf("SizeStdCompatible %T: have %d, want -1", data, n)
+		}
+		if err := WriteStdCompatible(io.Discard, LittleEndian, data); err == nil {
+			t.Errorf("WriteStdCompatible %T: have err == nil, want non-nil", data)
+		}
+		if err := ReadStdCompatible(bytes.NewReader(make([]byte, 100)), LittleEndian, data); err == nil {
+			t.Errorf("ReadStdCompatible %T: have err == nil, want non-nil", data)
+		}
+	}
+	var x int32
+	if err := ReadStdCompatible(bytes.NewReader(nil), LittleEndian, &x); err != io.EOF {
+		t.Errorf("ReadStdCompatible empty: have %v, want EOF", err)
+	}
+	if err := ReadStdCompatible(bytes.NewReader([]byte{1}), LittleEndian, &x); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadStdCompatible short: have %v, want ErrUnexpectedEOF", err)
+	}
+	var unexported struct {
+		A int8
+		b int8
+	}
+	if err := ReadStdCompatible(bytes.NewReader([]byte{1, 2}), LittleEndian, &unexported); err == nil {
+		t.Error("ReadStdCompatible unexported field: have err == nil, want non-nil")
+	}
+}
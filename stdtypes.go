@@ -0,0 +1,193 @@
+// encode/decode value types of standard library that can not be encoded by their fields.
+
+package binary
+
+import (
+	"encoding"
+	"fmt"
+	mathbig "math/big" //big is declared by tests
+	"net/netip"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// stdType is the codec of a standard library value type.
+// net.IP needs no codec, it is encoded as []byte.
+type stdType struct {
+	kind    int //stdTime, stdDuration, stdBigInt or stdBytes
+	minBits int //min number of bits to encode a value
+
+	//for stdBytes, called with pointer to the value
+	marshal   func(p interface{}) ([]byte, error)
+	unmarshal func(p interface{}, b []byte) error
+}
+
+const (
+	stdTime     = iota //see encodeTime
+	stdDuration        //varint
+	stdBigInt          //sign bit and length-prefixed big-endian bytes of abs value
+	stdBytes           //length-prefixed bytes of marshal
+)
+
+var (
+	tTime     = reflect.TypeOf(time.Time{})
+	tDuration = reflect.TypeOf(time.Duration(0))
+)
+
+var stdTypes = map[reflect.Type]*stdType{
+	tTime:                         {kind: stdTime, minBits: 8 + 8 + 1},
+	tDuration:                     {kind: stdDuration, minBits: 8},
+	reflect.TypeOf(mathbig.Int{}): {kind: stdBigInt, minBits: 1 + 8},
+	reflect.TypeOf(mathbig.Float{}): bytesType(
+		func(p interface{}) ([]byte, error) { return p.(*mathbig.Float).GobEncode() },
+		func(p interface{}, b []byte) error { return p.(*mathbig.Float).GobDecode(b) },
+	),
+	reflect.TypeOf(mathbig.Rat{}): bytesType(
+		func(p interface{}) ([]byte, error) { return p.(*mathbig.Rat).GobEncode() },
+		func(p interface{}, b []byte) error { return p.(*mathbig.Rat).GobDecode(b) },
+	),
+	reflect.TypeOf(netip.Addr{}):   bytesType(marshalBinary, unmarshalBinary),
+	reflect.TypeOf(netip.Prefix{}): bytesType(marshalBinary, unmarshalBinary),
+	reflect.TypeOf(url.URL{}):      bytesType(marshalBinary, unmarshalBinary),
+}
+
+func bytesType(marshal func(p interface{}) ([]byte, error), unmarshal func(p interface{}, b []byte) error) *stdType {
+	return &stdType{kind: stdBytes, minBits: 8, marshal: marshal, unmarshal: unmarshal}
+}
+
+func marshalBinary(p interface{}) ([]byte, error) {
+	return p.(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+func unmarshalBinary(p interface{}, b []byte) error {
+	return p.(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+}
+
+// stdTypeOf returns codec of t if it is a supported standard library type.
+func stdTypeOf(t reflect.Type) *stdType {
+	switch t.Kind() {
+	case reflect.Struct:
+		return stdTypes[t]
+	case reflect.Int64:
+		if t == tDuration {
+			return stdTypes[t]
+		}
+	}
+	return nil
+}
+
+// bits returns number of bits to encode v.
+func (std *stdType) bits(v reflect.Value) int {
+	switch std.kind {
+	case stdTime:
+		return bitsOfTime(v.Interface().(time.Time))
+	case stdDuration:
+		return SizeofVarint(v.Int()) * 8
+	case stdBigInt:
+		x := addrOf(v).(*mathbig.Int)
+		return 1 + sizeofString((x.BitLen()+7)/8)*8
+	}
+	return sizeofString(len(std.bytes(v))) * 8
+}
+
+// bytes returns the marshaled bytes of v for stdBytes.
+func (std *stdType) bytes(v reflect.Value) []byte {
+	b, err := std.marshal(addrOf(v))
+	if err != nil {
+		panic(fmt.Errorf("binary.Encoder.Value: %v", err))
+	}
+	return b
+}
+
+// std encode v of standard library type.
+func (encoder *Encoder) std(std *stdType, v reflect.Value) {
+	switch std.kind {
+	case stdTime:
+		encoder.time(v.Interface().(time.Time))
+	case stdDuration:
+		encoder.Varint(v.Int())
+	case stdBigInt:
+		x := addrOf(v).(*mathbig.Int)
+		encoder.Bool(x.Sign() < 0)
+		encoder.String(string(x.Bytes()))
+	default:
+		encoder.String(string(std.bytes(v)))
+	}
+}
+
+// std decode settable v of standard library type.
+func (decoder *Decoder) std(std *stdType, v reflect.Value) {
+	switch std.kind {
+	case stdTime:
+		v.Set(reflect.ValueOf(decoder.time()))
+	case stdDuration:
+		x, _ := decoder.Varint()
+		v.SetInt(x)
+	case stdBigInt:
+		x := v.Addr().Interface().(*mathbig.Int)
+		neg := decoder.Bool()
+		x.SetBytes(decoder.bytes())
+		if neg {
+			x.Neg(x)
+		}
+	default:
+		if err := std.unmarshal(v.Addr().Interface(), decoder.bytes()); err != nil {
+			panic(fmt.Errorf("binary.Decoder.Value: %v", err))
+		}
+	}
+}
+
+// time.Time is encoded as varint seconds since Unix epoch, uvarint nanoseconds
+// and a bool that reports whether zone offset in seconds follows as varint.
+// Location is not encoded, it is decoded as UTC, Local, or a fixed zone.
+func bitsOfTime(t time.Time) int {
+	bits := (SizeofVarint(t.Unix())+SizeofUvarint(uint64(t.Nanosecond())))*8 + 1
+	if t.Location() != time.UTC {
+		_, offset := t.Zone()
+		bits += SizeofVarint(int64(offset)) * 8
+	}
+	return bits
+}
+
+func (encoder *Encoder) time(t time.Time) {
+	encoder.Varint(t.Unix())
+	encoder.Uvarint(uint64(t.Nanosecond()))
+	hasZone := t.Location() != time.UTC
+	encoder.Bool(hasZone)
+	if hasZone {
+		_, offset := t.Zone()
+		encoder.Varint(int64(offset))
+	}
+}
+
+func (decoder *Decoder) time() time.Time {
+	sec, _ := decoder.Varint()
+	nsec, _ := decoder.Uvarint()
+	if nsec >= uint64(time.Second) {
+		panic(fmt.Errorf("binary.Decoder.Value: invalid nanoseconds %d of time", nsec))
+	}
+	t := time.Unix(sec, int64(nsec)).UTC()
+	if decoder.Bool() {
+		offset, _ := decoder.Varint()
+		if offset < -24*3600 || offset > 24*3600 {
+			panic(fmt.Errorf("binary.Decoder.Value: invalid zone offset %d of time", offset))
+		}
+		if _, local := t.In(time.Local).Zone(); int64(local) == offset {
+			t = t.In(time.Local)
+		} else {
+			t = t.In(time.FixedZone("", int(offset)))
+		}
+	}
+	return t
+}
+
+// addrOf returns pointer to v, or pointer to a copy of v if it is not addressable.
+func addrOf(v reflect.Value) interface{} {
+	if v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
+}
@@ -0,0 +1,245 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+// chunkWriter records size of every Write call.
+type chunkWriter struct {
+	bytes.Buffer
+	chunks []int
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.chunks = append(w.chunks, len(p))
+	return w.Buffer.Write(p)
+}
+
+type errWriter struct{ n int }
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.n -= len(p); w.n < 0 {
+		return 0, errors.New("write fail")
+	}
+	return len(p), nil
+}
+
+func TestStreamEncoder(t *testing.T) {
+	type boolStream struct {
+		A     bool
+		Bytes [20]uint8
+		B     bool
+		S     string
+		P     *uint32
+		C     bool
+	}
+	data := []interface{}{
+		&full,
+		&boolStream{A: true, B: true, S: "0123456789abcdef0123456789", C: true},
+		[]bool{true, false, true, true, false, false, true, true, true},
+	}
+	for _, bufSize := range []int{1, 3, 8, 17, 64, defaultStreamBufferSize} {
+		for i, v := range data {
+			w := &chunkWriter{}
+			encoder := NewStreamEncoder(w, DefaultEndian)
+			encoder.buff = make([]byte, bufSize)
+			if err := encoder.Value(v); err != nil {
+				t.Error(err)
+			}
+			if err := encoder.Flush(); err != nil {
+				t.Error(err)
+			}
+			if size := Sizeof(v); w.Len() != size {
+				t.Errorf("StreamEncoder(%d) case %d got size %d, need %d", bufSize, i, w.Len(), size)
+			}
+
+			//map order is random, so compare decoded value instead of bytes
+			r := reflect.New(reflect.Indirect(reflect.ValueOf(v)).Type())
+			if err := Decode(w.Bytes(), r.Interface()); err != nil {
+				t.Error(err)
+			}
+			if !reflect.DeepEqual(r.Elem().Interface(), reflect.Indirect(reflect.ValueOf(v)).Interface()) {
+				t.Errorf("StreamEncoder(%d) case %d got %#v\nneed %#v\n", bufSize, i, r.Elem().Interface(), v)
+			}
+			for _, n := range w.chunks {
+				if bufSize >= 16 && n > bufSize && n != len("0123456789abcdef0123456789") {
+					t.Errorf("StreamEncoder(%d) case %d write chunk %d", bufSize, i, n)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamEncoderBools(t *testing.T) {
+	w := &chunkWriter{}
+	encoder := NewStreamEncoder(w, BigEndian)
+	encoder.buff = make([]byte, 2)
+	encoder.Bool(true)
+	encoder.Uint32(0x11223344, false)
+	encoder.Bool(true)
+	encoder.Bool(false)
+	encoder.Bool(true)
+	if err := encoder.Flush(); err != nil {
+		t.Error(err)
+	}
+	encoder.Bool(true)
+	if err := encoder.Flush(); err != nil {
+		t.Error(err)
+	}
+	check := []byte{0xb, 0x11, 0x22, 0x33, 0x44, 0x1}
+	if !bytes.Equal(w.Bytes(), check) {
+		t.Errorf("StreamEncoderBools got %#v\nneed %#v\n", w.Bytes(), check)
+	}
+}
+
+func TestStreamEncoderError(t *testing.T) {
+	w := &errWriter{n: 10}
+	encoder := NewStreamEncoder(w, DefaultEndian)
+	encoder.buff = make([]byte, 8)
+	if err := encoder.Value(&full); err == nil {
+		t.Errorf("StreamEncoderError: have err == nil, want non-nil")
+	}
+	if err := Write(&errWriter{n: 10}, DefaultEndian, &full); err == nil {
+		t.Errorf("StreamEncoderError: have err == nil, want non-nil")
+	}
+	if err := NewEncoder(1).Flush(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteLarge(t *testing.T) {
+	data := make([]uint32, defaultStreamBufferSize)
+	for i := range data {
+		data[i] = uint32(i)
+	}
+	w := &chunkWriter{}
+	if err := Write(w, DefaultEndian, data); err != nil {
+		t.Error(err)
+	}
+	for _, n := range w.chunks {
+		if n > defaultStreamBufferSize {
+			t.Errorf("WriteLarge write chunk %d", n)
+		}
+	}
+	var r []uint32
+	if err := Decode(w.Bytes(), &r); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(r, data) {
+		t.Errorf("WriteLarge got %v", r)
+	}
+}
+
+// streamSerializer is a BinarySerializer whose size depends on its data.
+type streamSerializer struct{ S string }
+
+func (obj *streamSerializer) Size() int { return 1 + len(obj.S) }
+func (obj *streamSerializer) Encode(buffer []byte) ([]byte, error) {
+	buff, err := MakeEncodeBuffer(obj, buffer)
+	if err != nil {
+		return nil, err
+	}
+	buff = buff[:obj.Size()]
+	buff[0] = byte(len(obj.S))
+	copy(buff[1:], obj.S)
+	return buff, nil
+}
+func (obj *streamSerializer) Decode(buffer []byte) error {
+	if len(buffer) < 1 || len(buffer) < 1+int(buffer[0]) {
+		return io.ErrUnexpectedEOF
+	}
+	obj.S = string(buffer[1 : 1+int(buffer[0])])
+	return nil
+}
+
+func TestStreamDecoder(t *testing.T) {
+	values := []interface{}{
+		&full,
+		&streamSerializer{S: "0123456789abcdef0123456789"},
+		[]bool{true, false, true, true, false, false, true, true, true},
+		uint32(0x11223344),
+		"hello",
+	}
+	var b []byte
+	var offsets []int64
+	for _, v := range values {
+		var err error
+		if b, err = AppendEncode(b, v); err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, int64(len(b)))
+	}
+
+	readers := map[string]func() io.Reader{
+		"bytes":   func() io.Reader { return bytes.NewReader(b) },
+		"onebyte": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) },
+		"half":    func() io.Reader { return iotest.HalfReader(bytes.NewReader(b)) },
+	}
+	for name, newReader := range readers {
+		for _, bufSize := range []int{1, 5, defaultStreamBufferSize} {
+			decoder := NewStreamDecoder(newReader())
+			decoder.buff = make([]byte, 0, bufSize)
+			for i, v := range values {
+				r := reflect.New(reflect.Indirect(reflect.ValueOf(v)).Type())
+				if err := decoder.Value(r.Interface()); err != nil {
+					t.Fatalf("StreamDecoder(%s,%d) value %d: %v", name, bufSize, i, err)
+				}
+				if !reflect.DeepEqual(r.Elem().Interface(), reflect.Indirect(reflect.ValueOf(v)).Interface()) {
+					t.Errorf("StreamDecoder(%s,%d) value %d got %#v\nneed %#v", name, bufSize, i, r.Elem().Interface(), v)
+				}
+				if n := decoder.Consumed(); n != offsets[i] {
+					t.Errorf("StreamDecoder(%s,%d) value %d consumed %d, need %d", name, bufSize, i, n, offsets[i])
+				}
+			}
+			var x uint32
+			if err := decoder.Value(&x); err != io.EOF {
+				t.Errorf("StreamDecoder(%s,%d) got %v, want io.EOF", name, bufSize, err)
+			}
+		}
+	}
+}
+
+func TestStreamDecoderTruncated(t *testing.T) {
+	b, err := Encode(&full, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, len(b) / 2, len(b) - 1} {
+		var r fullStruct
+		decoder := NewStreamDecoder(bytes.NewReader(b[:n]))
+		if err := decoder.Value(&r); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("StreamDecoderTruncated(%d) got %v, want io.ErrUnexpectedEOF", n, err)
+		}
+	}
+
+	s, err := Encode(&streamSerializer{S: "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r streamSerializer
+	if err := NewStreamDecoder(bytes.NewReader(s[:3])).Value(&r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("StreamDecoderTruncated got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestReadSerializer(t *testing.T) {
+	b, err := Encode(&streamSerializer{S: "hello"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, 0x44, 0x33, 0x22, 0x11)
+	reader := bytes.NewReader(b)
+	var s streamSerializer
+	var u uint32
+	if err := Read(reader, LittleEndian, &s); err != nil || s.S != "hello" {
+		t.Errorf("ReadSerializer got %v %#v", err, s)
+	}
+	if err := Read(reader, LittleEndian, &u); err != nil || u != 0x11223344 {
+		t.Errorf("ReadSerializer got %v %#x", err, u)
+	}
+}
@@ -5,13 +5,55 @@ package binary
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 // RegStruct regist struct info to improve encoding/decoding efficiency.
 // Regist by a nil pointer is aviable.
 // RegStruct((*someStruct)(nil)) is recommended usage.
+// Unregisted structs are registed automatically on first use,
+// their field tags work the same as registed structs.
+// Field tag `binary:"id=N"` makes a versioned struct, see versioned.go.
+// Field tags `binary:"len=N"`, `binary:"lenprefix=u16"` and `binary:"lenfield=Name"`
+// change the length encoding of strings, slices and arrays, see length.go.
+// Field tags `binary:"be"` and `binary:"le"` change the byte order of a field.
+// Field tag `binary:"bits=N"` makes a bit field of integer, see bits.go.
+// It returns *TagError if a field has invalid tag.
+// It is safe to call RegStruct concurrently with encoding/decoding.
 func RegStruct(data interface{}) error {
-	return _structInfoMgr.regist(reflect.TypeOf(data))
+	if err := _structInfoMgr.regist(reflect.TypeOf(data)); err != nil {
+		return err
+	}
+	_planMgr.reset() //plans may use info of structs that are registed automatically
+	return nil
+}
+
+// RegisteredStructs returns all registed struct types, include the structs
+// registed automatically. It is sorted by package path and name for diagnostics.
+func RegisteredStructs() []reflect.Type {
+	reg := _structInfoMgr.load()
+	types := make([]reflect.Type, 0, len(reg))
+	for t := range reg {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if a, b := types[i].PkgPath(), types[j].PkgPath(); a != b {
+			return a < b
+		}
+		return types[i].String() < types[j].String()
+	})
+	return types
+}
+
+// DuplicateTypeError is returned by RegStruct if the struct type has been registed.
+type DuplicateTypeError struct {
+	Type reflect.Type
+}
+
+func (e *DuplicateTypeError) Error() string {
+	return fmt.Sprintf("binary: regist duplicate type %s", e.Type.String())
 }
 
 var _structInfoMgr structInfoMgr
@@ -20,38 +62,125 @@ func init() {
 	_structInfoMgr.init()
 }
 
+// structInfoMgr is a copy-on-write registry of struct info.
+// Readers load the map without lock, writers copy it and replace it.
 type structInfoMgr struct {
-	reg map[string]*structInfo
+	mu     sync.Mutex   //lock for writers
+	reg    atomic.Value //map[reflect.Type]*structInfo
+	custom atomic.Value //map[reflect.Type]customKind
 }
 
 func (mgr *structInfoMgr) init() {
-	mgr.reg = make(map[string]*structInfo)
+	mgr.reg.Store(make(map[reflect.Type]*structInfo))
+	mgr.custom.Store(make(map[reflect.Type]customKind))
+}
+
+func (mgr *structInfoMgr) load() map[reflect.Type]*structInfo {
+	return mgr.reg.Load().(map[reflect.Type]*structInfo)
+}
+
+// clone returns a copy of current registry for modify.
+func (mgr *structInfoMgr) clone() map[reflect.Type]*structInfo {
+	old := mgr.load()
+	reg := make(map[reflect.Type]*structInfo, len(old)+1)
+	for k, v := range old {
+		reg[k] = v
+	}
+	return reg
 }
+
 func (mgr *structInfoMgr) regist(t reflect.Type) error {
-	if _t, _, err := mgr.deepStructType(t, true); err == nil {
-		if mgr.query(_t) == nil {
-			p := &structInfo{}
-			if p.parse(_t) {
-				mgr.reg[p.identify] = p
-			}
-		} else {
-			return fmt.Errorf("binary: regist duplicate type %s", _t.String())
-		}
-	} else {
+	_t, _, err := mgr.deepStructType(t, true)
+	if err != nil {
 		return err
 	}
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if p := mgr.load()[_t]; p != nil && !p.auto {
+		return &DuplicateTypeError{Type: _t}
+	}
+	reg := mgr.clone()
+	if p := mgr.parse(reg, _t, false); p.err != nil {
+		return p.err
+	}
+	mgr.reg.Store(reg)
 	return nil
 }
 
+// parse parse struct t into reg.
+// Nested struct types are registed together if it is not auto registed.
+func (mgr *structInfoMgr) parse(reg map[reflect.Type]*structInfo, t reflect.Type, auto bool) *structInfo {
+	p := &structInfo{auto: auto}
+	reg[t] = p //before parse fields, to stop recursive types
+	p.parse(t)
+	if !auto {
+		for _, f := range p.fields { //deep regist if field is a struct
+			if _t, ok, _ := mgr.deepStructType(f.field.Type, false); ok && stdTypeOf(_t) == nil {
+				if q := reg[_t]; q == nil || q.auto {
+					mgr.parse(reg, _t, false)
+				}
+			}
+		}
+	}
+	return p
+}
+
+// query returns struct info of t.
+// It will regist t automatically if t is a valid struct but not registed.
 func (mgr *structInfoMgr) query(t reflect.Type) *structInfo {
 	if _t, _ok, _ := mgr.deepStructType(t, false); _ok {
-		if p, ok := mgr.reg[_t.String()]; ok {
+		if p, ok := mgr.load()[_t]; ok {
 			return p
 		}
+		return mgr.autoRegist(_t)
+	}
+	return nil
+}
+
+// lookup returns struct info of t without auto regist.
+func (mgr *structInfoMgr) lookup(t reflect.Type) *structInfo {
+	if _t, _ok, _ := mgr.deepStructType(t, false); _ok {
+		return mgr.load()[_t]
 	}
 	return nil
 }
 
+func (mgr *structInfoMgr) autoRegist(t reflect.Type) *structInfo {
+	if !validUserType(t) { //unsupported struct keeps the slow way
+		return nil
+	}
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if p, ok := mgr.load()[t]; ok {
+		return p
+	}
+	reg := mgr.clone()
+	p := mgr.parse(reg, t, true)
+	mgr.reg.Store(reg)
+	return p
+}
+
+// queryCustom returns customKind of t, it is checked once and cached.
+func (mgr *structInfoMgr) queryCustom(t reflect.Type) customKind {
+	if kind, ok := mgr.custom.Load().(map[reflect.Type]customKind)[t]; ok {
+		return kind
+	}
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	old := mgr.custom.Load().(map[reflect.Type]customKind)
+	custom := make(map[reflect.Type]customKind, len(old)+1)
+	for k, v := range old {
+		custom[k] = v
+	}
+	kind := checkCustom(t)
+	custom[t] = kind
+	mgr.custom.Store(custom)
+	return kind
+}
+
 func (mgr *structInfoMgr) deepStructType(t reflect.Type, needErr bool) (reflect.Type, bool, error) {
 	_t := t
 	for _t.Kind() == reflect.Ptr {
@@ -69,33 +198,100 @@ func (mgr *structInfoMgr) deepStructType(t reflect.Type, needErr bool) (reflect.
 
 //informatin of a struct
 type structInfo struct {
-	identify string //reflect.Type.String()
+	identify reflect.Type //type of the struct
 	fields   []*fieldInfo
+	auto     bool  //if it is registed automatically
+	err      error //invalid tag of fields
+
+	versioned bool           //if fields have tag id=N
+	ids       map[uint64]int //field index of ids for versioned struct
 }
 
+// encode struct v.
+// Path of the failed field is added to error while unwinding.
 func (info *structInfo) encode(encoder *Encoder, v reflect.Value) error {
 	//assert(v.Kind() == reflect.Struct, v.Type().String())
+	if info != nil && info.err != nil {
+		return info.err
+	}
+	if info.isVersioned() {
+		return info.encodeVersioned(encoder, v)
+	}
 	t := v.Type()
-	for i, n := 0, v.NumField(); i < n; i++ {
+	i, offset := 0, encoder.offset()
+	endian := encoder.endian //fields with be/le tag change it
+	var group []byte         //bytes of current bit field group
+	defer func() {
+		encoder.endian = endian
+		if e := recover(); e != nil {
+			tracePath(e, "."+t.Field(i).Name, t.Field(i).Type, offset)
+		}
+	}()
+	for n := v.NumField(); i < n; i++ {
 		// see comment for corresponding code in decoder.value()
 		finfo := info.field(i)
 		if f := v.Field(i); finfo.isValid(i, t) {
+			offset = encoder.offset()
+			encoder.endian = finfo.byteOrder(endian)
+			if finfo.hasBits() {
+				group = encoder.bitField(f, finfo, group)
+				continue
+			}
+			if finfo.hasLength() {
+				encoder.lengthValue(v, i, finfo)
+				continue
+			}
+			if kind := finfo.customKind(i, t); kind != customNone {
+				encoder.custom(f, kind)
+				continue
+			}
 			if err := encoder.value(f, finfo.isPacked()); err != nil {
-				return err
+				return addPath(err, "."+t.Field(i).Name, f.Type(), offset)
 			}
 		}
 	}
 	return nil
 }
 
+// decode struct v.
+// Path of the failed field is added to error while unwinding.
 func (info *structInfo) decode(decoder *Decoder, v reflect.Value) error {
+	if info != nil && info.err != nil {
+		return info.err
+	}
+	if info.isVersioned() {
+		return info.decodeVersioned(decoder, v)
+	}
 	t := v.Type()
 	//assert(t.Kind() == reflect.Struct, t.String())
-	for i, n := 0, v.NumField(); i < n; i++ {
+	i, offset := 0, decoder.Consumed()
+	endian := decoder.endian //fields with be/le tag change it
+	var group []byte         //bytes of current bit field group
+	defer func() {
+		decoder.endian = endian
+		if e := recover(); e != nil {
+			tracePath(e, "."+t.Field(i).Name, t.Field(i).Type, offset)
+		}
+	}()
+	for n := v.NumField(); i < n; i++ {
 		finfo := info.field(i)
 		if f := v.Field(i); finfo.isValid(i, t) {
+			offset = decoder.Consumed()
+			decoder.endian = finfo.byteOrder(endian)
+			if finfo.hasBits() {
+				group = decoder.bitField(f, finfo, group)
+				continue
+			}
+			if finfo.hasLength() {
+				decoder.lengthValue(v, i, finfo)
+				continue
+			}
+			if kind := finfo.customKind(i, t); kind != customNone {
+				decoder.custom(f, kind)
+				continue
+			}
 			if err := decoder.value(f, false, finfo.isPacked()); err != nil {
-				return err
+				return addPath(err, "."+t.Field(i).Name, f.Type(), offset)
 			}
 		}
 	}
@@ -104,25 +300,74 @@ func (info *structInfo) decode(decoder *Decoder, v reflect.Value) error {
 
 func (info *structInfo) decodeSkipByType(decoder *Decoder, t reflect.Type, packed bool) int {
 	//assert(t.Kind() == reflect.Struct, t.String())
-	sum := 0
+	if info.isVersioned() {
+		return info.skipVersioned(decoder)
+	}
+	start := decoder.Consumed()
+	endian := decoder.endian //fields with be/le tag change it
+	defer func() {
+		decoder.endian = endian
+	}()
+	var counters []uint64 //values of fields that are referenced by lenfield
 	for i, n := 0, t.NumField(); i < n; i++ {
 		f := info.field(i)
+		if !f.isValid(i, t) {
+			continue
+		}
 		ft := f.Type(i, t)
-		s := decoder.skipByType(ft, f.isPacked())
-		assert(s >= 0, "skip struct field fail:"+ft.String()) //I'm sure here cannot find unsupported type
-		sum += s
+		decoder.endian = f.byteOrder(endian)
+		switch {
+		case f.hasBits():
+			decoder.Skip(f.bits.minSize())
+		case f.hasLength():
+			var field uint64
+			if f.length.kind == lengthField {
+				field = counters[f.length.field]
+			}
+			decoder.skipLength(&f.length, ft, f.isPacked(), field)
+		case f.counter: //decode it for the length
+			if counters == nil {
+				counters = make([]uint64, n)
+			}
+			x := reflect.New(ft).Elem()
+			if err := decoder.value(x, false, f.isPacked()); err != nil {
+				panic(err)
+			}
+			counters[i] = lengthOf(x)
+		default:
+			s := decoder.skipByType(ft, f.isPacked())
+			assert(s >= 0, "skip struct field fail:"+ft.String()) //I'm sure here cannot find unsupported type
+		}
 	}
-	return sum
+	return int(decoder.Consumed() - start)
 }
 
 func (info *structInfo) bitsOfValue(v reflect.Value) int {
 	t := v.Type()
 	//assert(t.Kind() == reflect.Struct,t.String())
+	if info != nil && info.err != nil {
+		return -1
+	}
+	if info.isVersioned() {
+		return info.bitsOfVersioned(v)
+	}
 	sum := 0
 	for i, n := 0, v.NumField(); i < n; i++ {
 
 		if finfo := info.field(i); finfo.isValid(i, t) {
-			if s := bitsOfValue(v.Field(i), false, finfo.isPacked()); s >= 0 {
+			if finfo.hasBits() {
+				s := finfo.bits.bitsOf(v.Field(i))
+				if s < 0 {
+					return -1
+				}
+				sum += s
+			} else if finfo.hasLength() {
+				s := info.bitsOfLength(v, i)
+				if s < 0 {
+					return -1
+				}
+				sum += s
+			} else if s := bitsOfElem(v.Field(i), finfo.customKind(i, t), finfo.isPacked()); s >= 0 {
 				sum += s
 			} else {
 				return -1 //invalid field type
@@ -136,7 +381,13 @@ func (info *structInfo) sizeofNilPointer(t reflect.Type) int {
 	sum := 0
 	for i, n := 0, info.fieldNum(t); i < n; i++ {
 		if info.fieldValid(i, t) {
-			if s := sizeofNilPointer(info.field(i).Type(i, t)); s >= 0 {
+			f := info.field(i)
+			if s := sizeofNilPointer(f.Type(i, t)); s >= 0 {
+				if f.hasLength() {
+					s = f.length.minSize(f.Type(i, t))
+				} else if f.hasBits() {
+					s = f.bits.minSize()
+				}
 				sum += s
 			} else {
 				return -1 //invalid field type
@@ -159,29 +410,37 @@ func (info *structInfo) fieldNum(t reflect.Type) int {
 	return info.numField()
 }
 
-func (info *structInfo) parse(t reflect.Type) bool {
+func (info *structInfo) parse(t reflect.Type) {
 	//assert(t.Kind() == reflect.Struct, t.String())
-	info.identify = t.String()
+	info.identify = t
 	for i, n := 0, t.NumField(); i < n; i++ {
 		f := t.Field(i)
 
 		field := &fieldInfo{}
 		field.field = f
-		tag := f.Tag.Get("binary")
-		field.ignore = !isExported(f.Name) || tag == "ignore"
-		field.packed = tag == "packed"
+		tag, err := parseTag(f.Tag.Get("binary"))
+		field.ignore = !isExported(f.Name) || tag.ignore
+		field.packed = tag.packed
+		field.custom = checkCustom(f.Type)
+		field.id = tag.id
+		field.endian = tag.endian
+		if err == nil && tag.hasDef {
+			field.def, err = parseDefault(tag.def, f.Type)
+		}
+		if err == nil && tag.length != lengthUvarint && !field.ignore {
+			field.length, err = info.parseLength(t, i, tag)
+		}
+		if err == nil && tag.bits > 0 && !field.ignore {
+			field.bits.n, err = tag.bits, checkBits(f.Type, tag)
+		}
+		if err != nil && info.err == nil {
+			info.err = &TagError{Type: t, Field: f.Name, Err: err}
+		}
 
 		info.fields = append(info.fields, field)
-
-		//deep regist if field is a struct
-		if _t, ok, _ := _structInfoMgr.deepStructType(f.Type, false); ok {
-			if err := _structInfoMgr.regist(_t); err != nil {
-				//fmt.Printf("binary: internal regist duplicate type %s\n", _t.String())
-				continue
-			}
-		}
 	}
-	return true
+	info.groupBits()
+	info.checkVersioned(t)
 }
 
 func (info *structInfo) field(i int) *fieldInfo {
@@ -200,9 +459,16 @@ func (info *structInfo) numField() int {
 
 //informatin of a struct field
 type fieldInfo struct {
-	field  reflect.StructField
-	ignore bool //if this field is ignored
-	packed bool //if this ints field encode as varint/uvarint
+	field   reflect.StructField
+	ignore  bool          //if this field is ignored
+	packed  bool          //if this ints field encode as varint/uvarint
+	custom  customKind    //if this field serializes itself
+	id      uint64        //field id of versioned struct
+	def     reflect.Value //default value of versioned struct field
+	length  fieldLength   //length tag of string, slice and array, see length.go
+	counter bool          //if it is referenced by lenfield of another field
+	endian  Endian        //byte order of be/le tag, nil for Endian of the coder
+	bits    fieldBits     //bit field of tag bits=N, see bits.go
 }
 
 func (field *fieldInfo) Type(i int, t reflect.Type) reflect.Type {
@@ -224,10 +490,34 @@ func (field *fieldInfo) isValid(i int, t reflect.Type) bool {
 	return validField(t.Field(i)) // slow way to access field info
 }
 
+func (field *fieldInfo) hasLength() bool {
+	return field != nil && field.length.kind != lengthUvarint
+}
+
+// byteOrder returns byte order of be/le tag, or endian if the field has none.
+func (field *fieldInfo) byteOrder(endian Endian) Endian {
+	if field != nil && field.endian != nil {
+		return field.endian
+	}
+	return endian
+}
+
+func (field *fieldInfo) hasBits() bool {
+	return field != nil && field.bits.n > 0
+}
+
 func (field *fieldInfo) isPacked() bool {
 	return field != nil && field.packed
 }
 
+func (field *fieldInfo) customKind(i int, t reflect.Type) customKind {
+	if field != nil {
+		return field.custom
+	}
+
+	return customOf(t.Field(i).Type)
+}
+
 func queryStruct(t reflect.Type) *structInfo {
 	return _structInfoMgr.query(t)
 }
@@ -0,0 +1,166 @@
+// parse struct field tag `binary:"..."`.
+
+package binary
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldTag is the parsed field tag `binary:"opt1,opt2,..."`.
+// Options:
+//
+//	ignore     the field is not encoded
+//	packed     ints are encoded as varint/uvarint
+//	id=N       field number N > 0 of versioned struct, see versioned.go
+//	default=V  value of a missing field when decoding versioned struct
+//	len=N      string, slice or array of N elements without length prefix, see length.go
+//	lenprefix=u8/u16/u32  fixed-size length prefix of string, slice or array
+//	lenfield=Name  length of string, slice or array is the value of an earlier field
+//	be/le      numbers of the field are encoded in BigEndian/LittleEndian,
+//	           include elements and nested fields, instead of Endian of the coder
+//	bits=N     integer of N bits, consecutive bit fields share bytes, see bits.go
+//
+// Unknown options are ignored for compatibility.
+type fieldTag struct {
+	ignore   bool
+	packed   bool
+	id       uint64 //0 for not versioned
+	def      string //default value
+	hasDef   bool   //if default is set
+	length   lengthKind
+	lenN     int    //N of len=N
+	lenField string //Name of lenfield=Name
+	endian   Endian //byte order of be/le, nil for Endian of the coder
+	bits     int    //N of bits=N, 0 for not a bit field
+}
+
+// parseTag parse tag of struct field.
+func parseTag(tag string) (fieldTag, error) {
+	var ft fieldTag
+	if tag == "" {
+		return ft, nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(opt), "=")
+		switch name {
+		case "ignore":
+			ft.ignore = true
+		case "packed":
+			ft.packed = true
+		case "id":
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || id == 0 {
+				return ft, fmt.Errorf("invalid id %q, it must be a positive integer", value)
+			}
+			ft.id = id
+		case "default":
+			if !hasValue {
+				return ft, fmt.Errorf("missing value of default")
+			}
+			ft.def, ft.hasDef = value, true
+		case "be", "le":
+			if ft.endian != nil {
+				return ft, fmt.Errorf("only one of be and le can be set")
+			}
+			ft.endian = LittleEndian
+			if name == "be" {
+				ft.endian = BigEndian
+			}
+		case "bits":
+			n, err := strconv.ParseUint(value, 10, 8)
+			if err != nil || n == 0 || n > 64 {
+				return ft, fmt.Errorf("invalid bits %q, it must be in range 1-64", value)
+			}
+			ft.bits = int(n)
+		case "len", "lenprefix", "lenfield":
+			if ft.length != lengthUvarint {
+				return ft, fmt.Errorf("only one of len, lenprefix and lenfield can be set")
+			}
+			if err := ft.parseLength(name, value); err != nil {
+				return ft, err
+			}
+		}
+	}
+	return ft, nil
+}
+
+// parseLength parse length option name=value.
+func (ft *fieldTag) parseLength(name, value string) error {
+	switch name {
+	case "len":
+		n, err := strconv.ParseUint(value, 10, 31)
+		if err != nil {
+			return fmt.Errorf("invalid len %q, it must be a non-negative integer", value)
+		}
+		ft.length, ft.lenN = lengthFixed, int(n)
+	case "lenprefix":
+		switch value {
+		case "u8":
+			ft.length = lengthU8
+		case "u16":
+			ft.length = lengthU16
+		case "u32":
+			ft.length = lengthU32
+		default:
+			return fmt.Errorf("invalid lenprefix %q, it must be u8, u16 or u32", value)
+		}
+	case "lenfield":
+		if value == "" {
+			return fmt.Errorf("missing name of lenfield")
+		}
+		ft.length, ft.lenField = lengthField, value
+	}
+	return nil
+}
+
+// parseDefault parse default value s of type t.
+// Only bool, numbers and string are supported.
+func parseDefault(s string, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.Bool:
+		var x bool
+		x, err = strconv.ParseBool(s)
+		v.SetBool(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var x int64
+		x, err = strconv.ParseInt(s, 0, t.Bits())
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var x uint64
+		x, err = strconv.ParseUint(s, 0, t.Bits())
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		x, err = strconv.ParseFloat(s, t.Bits())
+		v.SetFloat(x)
+	case reflect.String:
+		v.SetString(s)
+	default:
+		return v, fmt.Errorf("default value of type %s is not supported", t.String())
+	}
+	if err != nil {
+		return v, fmt.Errorf("invalid default %q of type %s", s, t.String())
+	}
+	return v, nil
+}
+
+// TagError is returned by RegStruct, Encoder and Decoder if a struct field
+// has invalid tag `binary:"..."`.
+type TagError struct {
+	Type  reflect.Type // the struct type
+	Field string       // name of the field
+	Err   error
+}
+
+func (e *TagError) Error() string {
+	return fmt.Sprintf("binary: invalid tag of field %s.%s: %v", e.Type.String(), e.Field, e.Err)
+}
+
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,194 @@
+// encode/decode versioned structs that tolerate added and removed fields.
+
+package binary
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A struct is versioned if it's fields have tag `binary:"id=N"`.
+// Every encoded field of versioned struct must have a unique id.
+//
+// Versioned struct is encoded as a sequence of fields and a terminator uvarint 0,
+// every field is encoded as uvarint id, uvarint length and the encoded bytes
+// of field value. Bools of a field are packed in the field bytes only.
+//
+// Decoder skips fields with unknown id, and fills missing fields with
+// zero value or the default value of tag `binary:"id=N,default=V"`.
+// So that fields can be added or removed without breaking stored data,
+// but id of a field should never be reused for a different type.
+
+// checkVersioned checks ids of fields and set info.versioned.
+func (info *structInfo) checkVersioned(t reflect.Type) {
+	for _, f := range info.fields {
+		if f.id > 0 && !f.ignore {
+			info.versioned = true
+		}
+	}
+	ids := make(map[uint64]int)
+	for i, f := range info.fields {
+		var err error
+		switch {
+		case f.ignore:
+			continue
+		case !info.versioned && f.def.IsValid():
+			err = fmt.Errorf("default is valid for versioned struct only")
+		case info.versioned && f.id == 0:
+			err = fmt.Errorf("missing id of versioned struct field")
+		case info.versioned && f.length.kind != lengthUvarint:
+			err = fmt.Errorf("length tag is not valid for versioned struct")
+		case info.versioned && f.bits.n > 0:
+			err = fmt.Errorf("bit field is not valid for versioned struct")
+		case info.versioned:
+			if j, ok := ids[f.id]; ok {
+				err = fmt.Errorf("duplicate id %d of field %s", f.id, info.fields[j].field.Name)
+			}
+			ids[f.id] = i
+		}
+		if err != nil && info.err == nil {
+			info.err = &TagError{Type: t, Field: f.field.Name, Err: err}
+		}
+	}
+	if info.versioned {
+		info.ids = ids
+	}
+}
+
+// isVersioned returns if info is a versioned struct.
+func (info *structInfo) isVersioned() bool {
+	return info != nil && info.versioned
+}
+
+// bitsOfField returns number of bits to encode field i of v, or -1 if it fails.
+func (info *structInfo) bitsOfField(v reflect.Value, i int) int {
+	f := info.fields[i]
+	bits := bitsOfElem(v.Field(i), f.custom, f.packed)
+	if bits < 0 {
+		return -1
+	}
+	size := (bits + 7) / 8
+	return (SizeofUvarint(f.id) + sizeofString(size)) * 8
+}
+
+func (info *structInfo) bitsOfVersioned(v reflect.Value) int {
+	sum := SizeofUvarint(0) * 8 //terminator
+	for i, f := range info.fields {
+		if f.ignore {
+			continue
+		}
+		s := info.bitsOfField(v, i)
+		if s < 0 {
+			return -1
+		}
+		sum += s
+	}
+	return sum
+}
+
+// encodeVersioned encode versioned struct v.
+// Every field is encoded by a new Encoder on the reserved bytes,
+// so that it's bools are not packed with other fields.
+func (info *structInfo) encodeVersioned(encoder *Encoder, v reflect.Value) error {
+	t := v.Type()
+	i, offset := 0, encoder.offset()
+	defer func() {
+		if e := recover(); e != nil {
+			tracePath(e, "."+t.Field(i).Name, t.Field(i).Type, offset)
+		}
+	}()
+	for n := len(info.fields); i < n; i++ {
+		f := info.fields[i]
+		if f.ignore {
+			continue
+		}
+		fv := v.Field(i)
+		offset = encoder.offset()
+		bits := bitsOfElem(fv, f.custom, f.packed)
+		if bits < 0 {
+			return addPath(&UnsupportedTypeError{Type: fv.Type(), op: "binary.Encoder.Value"}, "."+t.Field(i).Name, fv.Type(), offset)
+		}
+		size := (bits + 7) / 8
+		encoder.Uvarint(f.id)
+		encoder.Uvarint(uint64(size))
+		sub := Encoder{canonical: encoder.canonical}
+		sub.endian = f.byteOrder(encoder.endian)
+		sub.written = encoder.offset()
+		sub.buff = encoder.reserve(size)
+		sub.resetBoolCoder()
+		sub.elem(fv, f.custom, f.packed)
+		if sub.pos != size {
+			panic(fmt.Errorf("binary.Encoder.Value: encoded %d bytes, but size is %d", sub.pos, size))
+		}
+	}
+	encoder.Uvarint(0)
+	return nil
+}
+
+// decodeVersioned decode versioned struct v.
+func (info *structInfo) decodeVersioned(decoder *Decoder, v reflect.Value) error {
+	t := v.Type()
+	i, offset := -1, decoder.Consumed()
+	defer func() {
+		if e := recover(); e != nil {
+			if i < 0 {
+				panic(e)
+			}
+			tracePath(e, "."+t.Field(i).Name, t.Field(i).Type, offset)
+		}
+	}()
+	for j, f := range info.fields { //missing fields
+		if f.ignore {
+			continue
+		}
+		if f.def.IsValid() {
+			v.Field(j).Set(f.def)
+		} else {
+			v.Field(j).Set(reflect.Zero(f.field.Type))
+		}
+	}
+
+	next := 0 //index of the expected next field
+	for {
+		i = -1
+		id, _ := decoder.Uvarint()
+		if id == 0 {
+			return nil
+		}
+		size := decoder.length("", 0, 8)
+		if next < len(info.fields) && info.fields[next].id == id {
+			i = next
+		} else if j, ok := info.ids[id]; ok {
+			i = j
+		}
+		if i < 0 { //removed or unknown field
+			decoder.Skip(size)
+			continue
+		}
+		next = i + 1
+
+		f := info.fields[i]
+		offset = decoder.Consumed()
+		sub := Decoder{limits: decoder.limits, depth: decoder.depth, strict: decoder.strict, zeroCopy: decoder.aliasing()}
+		sub.endian = f.byteOrder(decoder.endian)
+		sub.offset = offset
+		sub.start = decoder.start
+		sub.buff = decoder.reserve(size)
+		sub.resetBoolCoder()
+		sub.elem(v.Field(i), f.custom, f.packed)
+		if sub.pos != size {
+			panic(fmt.Errorf("binary.Decoder.Value: decoded %d bytes of field id=%d, but size is %d", sub.pos, id, size))
+		}
+	}
+}
+
+// skipVersioned skip a versioned struct, it returns number of bytes skipped.
+func (info *structInfo) skipVersioned(decoder *Decoder) int {
+	start := decoder.Consumed()
+	for {
+		if id, _ := decoder.Uvarint(); id == 0 {
+			return int(decoder.Consumed() - start)
+		}
+		decoder.Skip(decoder.length("", 0, 8))
+	}
+}
@@ -0,0 +1,149 @@
+package binary
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type recordV1 struct {
+	A    int    `binary:"id=1"`
+	B    string `binary:"id=2"`
+	C    bool   `binary:"id=3"`
+	Skip int    `binary:"ignore"`
+}
+
+type recordV2 struct {
+	D []uint16  `binary:"id=4"`
+	C bool      `binary:"id=3"`
+	A int       `binary:"id=1"`
+	E int32     `binary:"id=5,default=-7"`
+	F string    `binary:"id=6,default=x"`
+	G *recordV1 `binary:"id=7"`
+}
+
+type versionedOuter struct {
+	B bool
+	V recordV2
+	C bool
+	L []recordV1
+}
+
+func TestVersioned(t *testing.T) {
+	v1 := &recordV1{A: -1, B: "b", C: true, Skip: 1}
+	b, err := Encode(v1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//id, length, value... terminator
+	if need := []byte{1, 1, 1, 2, 2, 1, 'b', 3, 1, 1, 0}; !reflect.DeepEqual(b, need) {
+		t.Errorf("got % x\nneed % x", b, need)
+	}
+
+	//new decoder fills missing fields with default values
+	r2 := recordV2{D: []uint16{1}, E: 1, G: &recordV1{}}
+	if err := Decode(b, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if need := (recordV2{C: true, A: -1, E: -7, F: "x"}); !reflect.DeepEqual(r2, need) {
+		t.Errorf("got %#v\nneed %#v", r2, need)
+	}
+
+	//old decoder skips unknown fields
+	v2 := &recordV2{D: []uint16{1, 2}, C: true, A: 3, E: 4, F: "f", G: &recordV1{A: 5}}
+	if b, err = Encode(v2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if size := Sizeof(v2); size != len(b) {
+		t.Errorf("Sizeof got %d, need %d", size, len(b))
+	}
+	r1 := recordV1{B: "old", Skip: 2}
+	if err := Decode(b, &r1); err != nil {
+		t.Fatal(err)
+	}
+	if need := (recordV1{A: 3, C: true, Skip: 2}); r1 != need {
+		t.Errorf("got %#v\nneed %#v", r1, need)
+	}
+
+	//bools of fields are not packed with others
+	outer := &versionedOuter{B: true, V: *v2, C: true, L: []recordV1{*v1, {A: 1}}}
+	if b, err = Encode(outer, nil); err != nil {
+		t.Fatal(err)
+	}
+	if size := Sizeof(outer); size != len(b) {
+		t.Errorf("Sizeof got %d, need %d", size, len(b))
+	}
+	var r versionedOuter
+	if err := Decode(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	outer.L[0].Skip = 0
+	if !reflect.DeepEqual(&r, outer) {
+		t.Errorf("got %#v\nneed %#v", r, outer)
+	}
+
+	//skip elements that the array can not hold
+	var a [1]recordV1
+	if b, err = Encode(outer.L, nil); err != nil {
+		t.Fatal(err)
+	}
+	decoder := NewDecoder(b)
+	if err := decoder.Value(&a); err != nil || a[0] != outer.L[0] || decoder.Len() != len(b) {
+		t.Errorf("got %v %v, decoded %d of %d bytes", a, err, decoder.Len(), len(b))
+	}
+
+	//field length must match it's value
+	b, _ = Encode(v1, nil)
+	b[1] = 2 //length of A
+	var e *DecodeError
+	if err := Decode(b, &r1); !errors.As(err, &e) || e.Path != "recordV1.A" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestVersionedTagError(t *testing.T) {
+	cases := []interface{}{
+		&struct {
+			A int `binary:"id=1"`
+			B int `binary:"id=1"`
+		}{},
+		&struct {
+			A int `binary:"id=1"`
+			B int
+		}{},
+		&struct {
+			A int `binary:"id=0"`
+		}{},
+		&struct {
+			A int `binary:"default=1"`
+		}{},
+		&struct {
+			A int `binary:"id=1,default=x"`
+		}{},
+		&struct {
+			A []int `binary:"id=1,default=1"`
+		}{},
+	}
+	for i, c := range cases {
+		var e *TagError
+		if err := RegStruct(c); !errors.As(err, &e) {
+			t.Errorf("case %d: RegStruct got %v", i, err)
+		}
+		if _, err := Encode(c, nil); !errors.As(err, &e) {
+			t.Errorf("case %d: Encode got %v", i, err)
+		}
+		if err := Decode([]byte{0}, c); !errors.As(err, &e) {
+			t.Errorf("case %d: Decode got %v", i, err)
+		}
+	}
+
+	//ignored fields need no id
+	type ignored struct {
+		A int `binary:"id=1"`
+		B int `binary:"ignore,packed"`
+		c int
+	}
+	if err := RegStruct((*ignored)(nil)); err != nil {
+		t.Error(err)
+	}
+}
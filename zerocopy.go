@@ -0,0 +1,66 @@
+// zero-copy decoding of strings and byte slices.
+
+package binary
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// SetZeroCopy enable/disable zero-copy mode of Decoder.
+//
+// In zero-copy mode, decoded []byte values refer to the buffer of Decoder
+// instead of copies of it, and decoded strings share memory with the buffer.
+// So that they are valid only as long as the buffer is not modified:
+//
+//	the caller must not modify or reuse the buffer while decoded values are in use
+//	modifying a decoded []byte modifies the buffer, and strings that share it
+//	appending to a decoded []byte always makes a copy, because it's cap equals to it's len
+//	values from Decoder.String share the buffer too
+//
+// It has no effect on stream Decoder, because it's buffer is reused for
+// the following bytes.
+func (decoder *Decoder) SetZeroCopy(enable bool) {
+	decoder.zeroCopy = enable
+}
+
+// aliasing returns if decoded values can refer to the buffer.
+func (decoder *Decoder) aliasing() bool {
+	return decoder.zeroCopy && decoder.reader == nil
+}
+
+// makeString returns string of bytes b from buffer, that shares b in zero-copy mode.
+func (decoder *Decoder) makeString(b []byte) string {
+	if len(b) > 0 && decoder.aliasing() {
+		return *(*string)(unsafe.Pointer(&b))
+	}
+	return string(b)
+}
+
+// makeBytes returns []byte value of bytes b from buffer, that is b in zero-copy mode.
+func (decoder *Decoder) makeBytes(b []byte) []byte {
+	if len(b) > 0 && decoder.aliasing() {
+		return b[:len(b):len(b)] //append must not overwrite the buffer
+	}
+	s := make([]byte, len(b))
+	copy(s, b)
+	return s
+}
+
+// isBytesType returns if slice or array type t is []byte or [N]byte,
+// whose elements are not custom, so that it can be decoded by copy.
+func isBytesType(t reflect.Type) bool {
+	return t.Elem().Kind() == reflect.Uint8 && customOf(t.Elem()) == customNone
+}
+
+// byteSlice decode []byte or [N]byte v by copy, or refer to the buffer in zero-copy mode.
+func (decoder *Decoder) byteSlice(v reflect.Value) {
+	size := decoder.SliceLen(8)
+	b := decoder.reserve(size)
+	switch {
+	case v.Kind() == reflect.Array: //bytes out of array are skipped
+		copy(v.Bytes(), b)
+	case size > 0: //empty slice is not changed, as other slices
+		v.SetBytes(decoder.makeBytes(b))
+	}
+}
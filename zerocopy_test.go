@@ -0,0 +1,104 @@
+package binary
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type zeroCopyBlob []byte
+
+type zeroCopyStruct struct {
+	S string
+	B []byte
+	A [4]byte
+	N zeroCopyBlob
+	L []byte `binary:"lenprefix=u8"`
+	F string `binary:"len=4"`
+}
+
+func TestZeroCopy(t *testing.T) {
+	x := zeroCopyStruct{
+		S: "str",
+		B: []byte("bytes"),
+		A: [4]byte{1, 2, 3, 4},
+		N: zeroCopyBlob("blob"),
+		L: []byte("len"),
+		F: "fix",
+	}
+	for _, zeroCopy := range []bool{false, true} {
+		b, err := Encode(&x, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var y zeroCopyStruct
+		decoder := NewDecoder(b)
+		decoder.SetZeroCopy(zeroCopy)
+		if err := decoder.Value(&y); err != nil || !reflect.DeepEqual(y, x) {
+			t.Fatalf("zeroCopy=%v: have %+v, %v", zeroCopy, y, err)
+		}
+		if cap(y.B) != len(y.B) || cap(y.N) != len(y.N) || cap(y.L) != len(y.L) {
+			t.Errorf("zeroCopy=%v: cap of bytes are larger than len", zeroCopy)
+		}
+
+		for i := range b { //only the aliasing values are changed
+			b[i] = 'x'
+		}
+		want := x
+		if zeroCopy {
+			want.S, want.B, want.N, want.L, want.F = "xxx", []byte("xxxxx"), zeroCopyBlob("xxxx"), []byte("xxx"), "xxx"
+		}
+		if !reflect.DeepEqual(y, want) {
+			t.Errorf("zeroCopy=%v: after buffer changed have %+v, want %+v", zeroCopy, y, want)
+		}
+	}
+
+	//stream Decoder copies
+	var w bytes.Buffer
+	for _, s := range []string{"first", "second"} {
+		if err := Write(&w, DefaultEndian, []byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decoder := NewStreamDecoder(&w)
+	decoder.SetZeroCopy(true)
+	var first, second []byte
+	if err := decoder.Value(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoder.Value(&second); err != nil || string(first) != "first" || string(second) != "second" {
+		t.Errorf("stream Decoder: have %q %q, %v", first, second, err)
+	}
+}
+
+func TestByteSlice(t *testing.T) {
+	b, err := Encode([]byte{1, 2, 3}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = append(b, 0x7f)
+
+	//bytes out of array are skipped
+	var a [2]byte
+	var end uint8
+	decoder := NewDecoder(b)
+	if err := decoder.Value(&a); err != nil || a != [2]byte{1, 2} {
+		t.Errorf("Decode array: have %v, %v", a, err)
+	}
+	if err := decoder.Value(&end); err != nil || end != 0x7f {
+		t.Errorf("Decode after array: have %x, %v", end, err)
+	}
+
+	//limits of slice
+	var s zeroCopyBlob
+	decoder = NewDecoder(b)
+	decoder.SetLimits(Limits{MaxSliceLen: 2})
+	if err := decoder.Value(&s); err == nil {
+		t.Errorf("Decode over MaxSliceLen: have %v", s)
+	}
+	var truncated *TruncatedError
+	if err := Decode(b[:2], &s); !errors.As(err, &truncated) {
+		t.Errorf("Decode truncated: have %v, want *TruncatedError", err)
+	}
+}